@@ -143,6 +143,32 @@ func MONOf(i, alpha float32) Color {
 	return RGBAf(i, i, i, alpha)
 }
 
+// ColorTemperature returns opaque color value of black body radiation at the specified temperature in Kelvin.
+// It uses the common curve fit approximation. Temperature is clamped to 1000K..12000K.
+func ColorTemperature(kelvin float32) Color {
+	t := float64(clampF(kelvin, 1000, 12000)) / 100.0
+	var r, g, b float64
+	if t <= 66 {
+		r = 255
+		g = 99.4708025861*math.Log(t) - 161.1195681661
+	} else {
+		r = 329.698727446 * math.Pow(t-60, -0.1332047592)
+		g = 288.1221695283 * math.Pow(t-60, -0.0755148492)
+	}
+	if t >= 66 {
+		b = 255
+	} else if t <= 19 {
+		b = 0
+	} else {
+		b = 138.5177312231*math.Log(t-10) - 305.0447927307
+	}
+	return RGBf(
+		clampF(float32(r/255.0), 0, 1),
+		clampF(float32(g/255.0), 0, 1),
+		clampF(float32(b/255.0), 0, 1),
+	)
+}
+
 // LerpRGBA linearly interpolates from color c0 to c1, and returns resulting color value.
 func LerpRGBA(c0, c1 Color, u float32) Color {
 	u = clampF(u, 0.0, 1.0)
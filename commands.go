@@ -0,0 +1,116 @@
+package nanovgo
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// MarshalCommands serializes the current path commands.
+// The data starts with "NVGC" magic and format version, followed by the command count and
+// the command values as little endian float32. Coordinates are stored after transformation.
+func (ctx *Context) MarshalCommands() []byte {
+	data := make([]byte, 12+len(ctx.commands)*4)
+	copy(data, nvgCommandsMagic)
+	binary.LittleEndian.PutUint32(data[4:], nvgCommandsVersion)
+	binary.LittleEndian.PutUint32(data[8:], uint32(len(ctx.commands)))
+	for i, v := range ctx.commands {
+		binary.LittleEndian.PutUint32(data[12+i*4:], math.Float32bits(v))
+	}
+	return data
+}
+
+// UnmarshalCommands replaces the current path with the commands serialized by MarshalCommands().
+// Since the stored coordinates are already transformed, the current transform is not applied again.
+// The current path is kept as is if the data is broken.
+func (ctx *Context) UnmarshalCommands(data []byte) error {
+	if len(data) < 12 || string(data[:4]) != nvgCommandsMagic {
+		return errors.New("invalid command data header")
+	}
+	if version := binary.LittleEndian.Uint32(data[4:]); version != nvgCommandsVersion {
+		return errors.New("unsupported command data version")
+	}
+	count := int(binary.LittleEndian.Uint32(data[8:]))
+	if len(data)-12 != count*4 {
+		return errors.New("invalid command data length")
+	}
+	commands := make([]float32, count)
+	for i := range commands {
+		commands[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[12+i*4:]))
+	}
+
+	// Validate command structure to not break path flattening.
+	lastPointIdx, moveToIdx := -1, -1
+	for i := 0; i < len(commands); {
+		var size int
+		switch nvgCommands(commands[i]) {
+		case nvgMOVETO, nvgLINETO:
+			size = 3
+		case nvgBEZIERTO:
+			size = 7
+		case nvgQUADTO:
+			size = 5
+		case nvgCLOSE:
+			size = 1
+		case nvgWINDING:
+			size = 2
+		default:
+			return errors.New("invalid command in command data")
+		}
+		if i+size > len(commands) {
+			return errors.New("truncated command in command data")
+		}
+		for _, v := range commands[i+1 : i+size] {
+			if !isFiniteF(v) {
+				return errors.New("non-finite coordinate in command data")
+			}
+		}
+		if size > 2 {
+			lastPointIdx = i + size - 2
+		}
+		if nvgCommands(commands[i]) == nvgMOVETO {
+			moveToIdx = i + 1
+		}
+		i += size
+	}
+
+	ctx.BeginPath()
+	ctx.commands = append(ctx.commands, commands...)
+	ctx.lastPointIdx, ctx.moveToIdx = lastPointIdx, moveToIdx
+	if lastPointIdx >= 0 {
+		// commandX/Y are kept in user space.
+		ctx.commandX, ctx.commandY = ctx.getState().xform.Inverse().TransformPoint(commands[lastPointIdx], commands[lastPointIdx+1])
+	}
+	return nil
+}
+
+// WalkCommands calls visit for each command of the current path, in order. pts has x and y of the points of
+// the command as stored, i.e. transformed by the transform at the time they were added: one point for
+// CommandMoveTo and CommandLineTo, three for CommandBezierTo and two for CommandQuadTo. It is empty for
+// CommandClose and has the Winding value for CommandWinding. pts refers to the path, so it must not be
+// modified or kept after the call.
+func (ctx *Context) WalkCommands(visit func(cmd CommandKind, pts []float32)) {
+	commands := ctx.commands
+	for i := 0; i < len(commands); {
+		var kind CommandKind
+		var size int
+		switch nvgCommands(commands[i]) {
+		case nvgMOVETO:
+			kind, size = CommandMoveTo, 3
+		case nvgLINETO:
+			kind, size = CommandLineTo, 3
+		case nvgBEZIERTO:
+			kind, size = CommandBezierTo, 7
+		case nvgQUADTO:
+			kind, size = CommandQuadTo, 5
+		case nvgCLOSE:
+			kind, size = CommandClose, 1
+		case nvgWINDING:
+			kind, size = CommandWinding, 2
+		default:
+			return
+		}
+		visit(kind, commands[i+1:i+size:i+size])
+		i += size
+	}
+}
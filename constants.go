@@ -29,6 +29,20 @@ const (
 	Clockwise Direction = 2
 )
 
+// ArcFlattenMode is used with Context.SetArcFlatten for the segments of arcs, circles and ellipses
+type ArcFlattenMode int
+
+// ArcBezier makes arcs of cubic bezier segments
+const ArcBezier ArcFlattenMode = 0
+
+// ArcLines makes arcs of line segments, segmentsPerQuadrant (at least 1) for each 90 degrees
+func ArcLines(segmentsPerQuadrant int) ArcFlattenMode {
+	if segmentsPerQuadrant < 1 {
+		segmentsPerQuadrant = 1
+	}
+	return ArcFlattenMode(segmentsPerQuadrant)
+}
+
 // LineCap is used for line cap and joint
 type LineCap int
 
@@ -45,6 +59,28 @@ const (
 	Miter
 )
 
+// StrokeAlign is used for stroke position relative to the path
+type StrokeAlign int
+
+const (
+	// StrokeCenter centers stroke on the path (default value)
+	StrokeCenter StrokeAlign = iota
+	// StrokeInner places stroke inside of the path
+	StrokeInner
+	// StrokeOuter places stroke outside of the path
+	StrokeOuter
+)
+
+// OverlapMode is used for coverage of self-overlapping strokes
+type OverlapMode int
+
+const (
+	// OverlapNaive draws each stroke segment as is, so overlapping parts are blended twice (default value)
+	OverlapNaive OverlapMode = iota
+	// OverlapUnified draws each pixel of the stroke only once
+	OverlapUnified
+)
+
 // Align is used for text location
 type Align int
 
@@ -65,6 +101,36 @@ const (
 	AlignBaseline Align = 1 << 6
 )
 
+// TextDecoration is used for lines drawn with text, the values can be combined
+type TextDecoration int
+
+const (
+	// DecorationNone draws text only (default value)
+	DecorationNone TextDecoration = 0
+	// DecorationUnderline draws a line below the baseline
+	DecorationUnderline TextDecoration = 1 << 0
+	// DecorationStrikethrough draws a line through the middle of lower case letters
+	DecorationStrikethrough TextDecoration = 1 << 1
+)
+
+// CommandKind is the kind of path command reported by Context.WalkCommands()
+type CommandKind int
+
+const (
+	// CommandMoveTo starts new sub-path at the point
+	CommandMoveTo CommandKind = iota
+	// CommandLineTo adds line segment to the point
+	CommandLineTo
+	// CommandBezierTo adds cubic bezier segment with two control points and the end point
+	CommandBezierTo
+	// CommandQuadTo adds quadratic bezier segment with the control point and the end point
+	CommandQuadTo
+	// CommandClose closes the sub-path
+	CommandClose
+	// CommandWinding sets the winding of the sub-path, the value is the Winding
+	CommandWinding
+)
+
 // ImageFlags is used for setting image object
 type ImageFlags int
 
@@ -79,6 +145,22 @@ const (
 	ImageFlippy ImageFlags = 1 << 3
 	// ImagePreMultiplied specifies image data has premultiplied alpha.
 	ImagePreMultiplied ImageFlags = 1 << 4
+	// ImageNearest samples image with nearest filtering instead of linear.
+	ImageNearest ImageFlags = 1 << 5
+)
+
+// ImageFit is used for placing an image in a box by Context.DrawImage()
+type ImageFit int
+
+const (
+	// FitStretch scales the image to the box, ignoring its aspect ratio.
+	FitStretch ImageFit = iota
+	// FitContain scales the image to fit inside the box keeping its aspect ratio. The rest of the box is left transparent.
+	FitContain
+	// FitCover scales the image to cover the box keeping its aspect ratio. The image is cropped to the box.
+	FitCover
+	// FitNone keeps the image size, centered in the box and cropped to it.
+	FitNone
 )
 
 // Winding is used for changing filling strategy
@@ -4,12 +4,25 @@ const (
 	nvgInitFontImageSize = 512
 	nvgMaxFontImageSize  = 2048
 	nvgMaxFontImages     = 4
+	nvgFontImageSizeMin  = 64
+	nvgFontImageSizeMax  = 16384
 
 	nvgInitCommandsSize = 256
 	nvgInitPointsSize   = 128
 	nvgInitPathsSize    = 16
 	nvgInitVertsSize    = 256
 	nvgMaxStates        = 32
+	nvgMaxArcDivs       = 64
+	nvgTessDepth        = 10
+	nvgMaxTessDepth     = 20
+	nvgDefaultDPI       = 96
+	nvgMinPixelRatio    = 1.0 / 64
+	nvgMaxMetaballCells = 512
+	nvgDotImageSize     = 64
+	nvgMaxDashSteps     = 1 << 17
+
+	nvgCommandsMagic   = "NVGC"
+	nvgCommandsVersion = 1
 )
 
 type nvgCommands int
@@ -20,6 +33,7 @@ const (
 	nvgBEZIERTO
 	nvgCLOSE
 	nvgWINDING
+	nvgQUADTO
 )
 
 type nvgPointFlags int
@@ -123,3 +123,11 @@ func (atlas *Atlas) reset(width, height int) {
 		atlas.nodes[0].width = int16(width)
 	}
 }
+
+func (atlas *Atlas) usedArea() int {
+	area := 0
+	for _, node := range atlas.nodes {
+		area += int(node.y) * int(node.width)
+	}
+	return area
+}
@@ -39,11 +39,14 @@ type State struct {
 	size    float32
 	blur    float32
 	spacing float32
+	tabs    []float32
+	tabUnit float32
 }
 
 type GlyphKey struct {
 	codePoint  rune
 	size, blur int16
+	byIndex    bool // codePoint is glyph index
 }
 
 type Glyph struct {
@@ -62,6 +65,8 @@ type Font struct {
 	ascender  float32
 	descender float32
 	lineh     float32
+	capHeight float32
+	xHeight   float32
 	glyphs    map[GlyphKey]*Glyph
 	lut       []int
 }
@@ -83,6 +88,10 @@ type TextIterator struct {
 	NextIndex                          int
 	End                                int
 	Runes                              []rune
+	TabOriginX                         float32 // Tab stops are measured from this position.
+
+	tabs    []float32
+	tabUnit float32
 }
 
 type FontStash struct {
@@ -97,6 +106,8 @@ type FontStash struct {
 	scratch     []byte
 	nscratch    int
 	state       State
+	noSnapX     bool
+	noSnapY     bool
 }
 
 func New(width, height int) *FontStash {
@@ -142,6 +153,7 @@ func (stash *FontStash) AddFontFromMemory(name string, data []byte, freeData uin
 	}
 	ascent, descent, lineGap := fontInstance.GetFontVMetrics()
 	fh := float32(ascent - descent)
+	capHeight, xHeight := fontInstance.GetFontCapAndXHeight()
 
 	font := &Font{
 		glyphs:    make(map[GlyphKey]*Glyph),
@@ -152,11 +164,18 @@ func (stash *FontStash) AddFontFromMemory(name string, data []byte, freeData uin
 		ascender:  float32(ascent) / fh,
 		descender: float32(descent) / fh,
 		lineh:     (fh + float32(lineGap)) / fh,
+		capHeight: float32(capHeight) / fh,
+		xHeight:   float32(xHeight) / fh,
 	}
 	stash.fonts = append(stash.fonts, font)
 	return len(stash.fonts) - 1
 }
 
+// FontCount returns the number of loaded fonts. Valid font ids are 0..FontCount()-1.
+func (stash *FontStash) FontCount() int {
+	return len(stash.fonts)
+}
+
 func (stash *FontStash) GetFontByName(name string) int {
 	for i, font := range stash.fonts {
 		if font.name == name {
@@ -174,6 +193,26 @@ func (stash *FontStash) SetSpacing(spacing float32) {
 	stash.state.spacing = spacing
 }
 
+// SetTabStops sets the distances between tab stops, multiplied by scale. The last distance repeats.
+// A tab moves the pen to the next stop from the beginning of the text. Without stops, a tab is a glyph.
+func (stash *FontStash) SetTabStops(widths []float32, scale float32) {
+	stash.state.tabs = widths
+	stash.state.tabUnit = scale
+}
+
+// nextTabStop returns the position of the first tab stop after x.
+func nextTabStop(tabs []float32, unit, x float32) float32 {
+	var stop float32
+	for _, width := range tabs {
+		stop += width * unit
+		if stop > x {
+			return stop
+		}
+	}
+	last := tabs[len(tabs)-1] * unit
+	return stop + float32(math.Floor(float64((x-stop)/last))+1)*last
+}
+
 func (stash *FontStash) SetBlur(blur float32) {
 	stash.state.blur = blur
 }
@@ -186,6 +225,23 @@ func (stash *FontStash) SetFont(font int) {
 	stash.state.font = font
 }
 
+// SetPixelSnap sets whether glyph positions and advances are rounded to whole pixels (enabled by default).
+func (stash *FontStash) SetPixelSnap(enabled bool) {
+	stash.SetPixelSnapAxes(enabled, enabled)
+}
+
+// SetPixelSnapAxes sets whether glyph positions are rounded to whole pixels horizontally (with the advances)
+// and vertically.
+func (stash *FontStash) SetPixelSnapAxes(x, y bool) {
+	stash.noSnapX = !x
+	stash.noSnapY = !y
+}
+
+// PixelSnapAxes returns whether glyph positions are rounded to whole pixels horizontally and vertically.
+func (stash *FontStash) PixelSnapAxes() (x, y bool) {
+	return !stash.noSnapX, !stash.noSnapY
+}
+
 func (stash *FontStash) GetFontName() string {
 	return stash.fonts[stash.state.font].name
 }
@@ -200,6 +256,17 @@ func (stash *FontStash) VerticalMetrics() (float32, float32, float32) {
 	return font.ascender * iSize / 10.0, font.descender * iSize / 10.0, font.lineh * iSize / 10.0
 }
 
+// CapAndXHeight returns the cap height and the x-height of the current font at the current size.
+func (stash *FontStash) CapAndXHeight() (float32, float32) {
+	state := stash.state
+	if len(stash.fonts) < state.font+1 {
+		return -1, -1
+	}
+	font := stash.fonts[state.font]
+	iSize := float32(int16(state.size * 10.0))
+	return font.capHeight * iSize / 10.0, font.xHeight * iSize / 10.0
+}
+
 func (stash *FontStash) LineBounds(y float32) (minY, maxY float32) {
 	state := stash.state
 	if len(stash.fonts) < state.font+1 {
@@ -257,6 +324,11 @@ func (stash *FontStash) ResetAtlas(width, height int) {
 	stash.addWhiteRect(2, 2)
 }
 
+// AtlasUsage returns the area covered by the atlas skyline and the whole atlas area, in pixels (bytes).
+func (stash *FontStash) AtlasUsage() (used, total int) {
+	return stash.atlas.usedArea(), stash.atlas.width * stash.atlas.height
+}
+
 func (stash *FontStash) TextBounds(x, y float32, str string) (float32, []float32) {
 	return stash.TextBoundsOfRunes(x, y, []rune(str))
 }
@@ -283,7 +355,14 @@ func (stash *FontStash) TextBoundsOfRunes(x, y float32, runes []rune) (float32,
 
 	for _, codePoint := range runes {
 		glyph := stash.getGlyph(font, codePoint, size, blur)
-		if glyph != nil {
+		if codePoint == '\t' && len(state.tabs) > 0 {
+			// Tabs have no shape.
+			x = startX + nextTabStop(state.tabs, state.tabUnit, x-startX)
+			prevGlyphIndex = -1
+			if glyph != nil {
+				prevGlyphIndex = glyph.Index
+			}
+		} else if glyph != nil {
 			var quad Quad
 			quad, x, y = stash.getQuad(font, prevGlyphIndex, glyph, scale, state.spacing, x, y)
 			if quad.X0 < minX {
@@ -356,6 +435,9 @@ func (stash *FontStash) TextIterForRunes(x, y float32, runes []rune) *TextIterat
 		CodePoint:    0,
 		PrevGlyph:    nil,
 		Runes:        runes,
+		TabOriginX:   x,
+		tabs:         state.tabs,
+		tabUnit:      state.tabUnit,
 	}
 	return iter
 }
@@ -378,7 +460,11 @@ func (iter *TextIterator) Next() (quad Quad, ok bool) {
 	if iter.PrevGlyph != nil {
 		prevGlyphIndex = iter.PrevGlyph.Index
 	}
-	if glyph != nil {
+	if iter.CodePoint == '\t' && len(iter.tabs) > 0 {
+		// Tabs have an empty quad up to the next stop.
+		iter.NextX = iter.TabOriginX + nextTabStop(iter.tabs, iter.tabUnit, iter.X-iter.TabOriginX)
+		quad = Quad{X0: iter.X, Y0: iter.Y, X1: iter.NextX, Y1: iter.Y}
+	} else if glyph != nil {
 		quad, iter.NextX, iter.NextY = iter.stash.getQuad(font, prevGlyphIndex, glyph, iter.Scale, iter.Spacing, iter.NextX, iter.NextY)
 	}
 	iter.PrevGlyph = glyph
@@ -436,7 +522,6 @@ func (stash *FontStash) getGlyph(font *Font, codePoint rune, size, blur int) *Gl
 	if blur > 20 {
 		blur = 20
 	}
-	pad := blur + 2
 	glyphKey := GlyphKey{
 		codePoint: codePoint,
 		size:      int16(size),
@@ -446,8 +531,35 @@ func (stash *FontStash) getGlyph(font *Font, codePoint rune, size, blur int) *Gl
 	if ok {
 		return glyph
 	}
+	return stash.rasterizeGlyph(font, glyphKey, font.getGlyphIndex(codePoint))
+}
+
+func (stash *FontStash) getGlyphByIndex(font *Font, index, size, blur int) *Glyph {
+	if size < 0 {
+		return nil
+	}
+	if blur > 20 {
+		blur = 20
+	}
+	glyphKey := GlyphKey{
+		codePoint: rune(index),
+		size:      int16(size),
+		blur:      int16(blur),
+		byIndex:   true,
+	}
+	glyph, ok := font.glyphs[glyphKey]
+	if ok {
+		return glyph
+	}
+	return stash.rasterizeGlyph(font, glyphKey, index)
+}
+
+func (stash *FontStash) rasterizeGlyph(font *Font, glyphKey GlyphKey, index int) *Glyph {
+	size := int(glyphKey.size)
+	blur := int(glyphKey.blur)
+	pad := blur + 2
+	codePoint := glyphKey.codePoint
 	scale := font.getPixelHeightScale(float32(size) / 10.0)
-	index := font.getGlyphIndex(codePoint)
 	advance, _, x0, y0, x1, y1 := font.buildGlyphBitmap(index, scale)
 	gw := x1 - x0 + pad*2
 	gh := y1 - y0 + pad*2
@@ -458,7 +570,7 @@ func (stash *FontStash) getGlyph(font *Font, codePoint rune, size, blur int) *Gl
 	gr := gx + gw
 	gb := gy + gh
 	width := stash.params.width
-	glyph = &Glyph{
+	glyph := &Glyph{
 		codePoint: codePoint,
 		Index:     index,
 		size:      int16(size),
@@ -485,7 +597,7 @@ func (stash *FontStash) getGlyph(font *Font, codePoint rune, size, blur int) *Gl
 	}
 	if blur > 0 {
 		stash.nscratch = 0
-		stash.blur(gx, gy, gw, gh, blur)
+		stash.blur(stash.textureData, width, gx, gy, gw, gh, blur)
 	}
 
 	stash.dirtyRect[0] = fons__mini(stash.dirtyRect[0], gx)
@@ -496,12 +608,112 @@ func (stash *FontStash) getGlyph(font *Font, codePoint rune, size, blur int) *Gl
 	return glyph
 }
 
+// GlyphQuadByIndex returns the quad of the glyph specified by glyph index of current font, size and blur
+// with its origin at (x,y). Kerning, spacing and alignment are not applied.
+// ok is false when the glyph doesn't fit into the atlas.
+func (stash *FontStash) GlyphQuadByIndex(index int, x, y float32) (quad Quad, ok bool) {
+	state := stash.state
+	if len(stash.fonts) < state.font+1 || state.font < 0 {
+		return Quad{}, false
+	}
+	font := stash.fonts[state.font]
+	glyph := stash.getGlyphByIndex(font, index, int(state.size*10.0), int(state.blur))
+	if glyph == nil {
+		return Quad{}, false
+	}
+	quad, _, _ = stash.getQuad(font, -1, glyph, font.getPixelHeightScale(state.size), 0, x, y)
+	return quad, true
+}
+
+// GlyphAdvances returns the advance widths of the runes with current font, size and spacing.
+// Each advance includes the kerning and spacing to the following rune, so it is the distance
+// TextIterator moves the pen. Glyphs are not rasterized, so the atlas is not touched.
+func (stash *FontStash) GlyphAdvances(runes []rune) []float32 {
+	state := stash.state
+	if len(stash.fonts) < state.font+1 || state.font < 0 {
+		return nil
+	}
+	font := stash.fonts[state.font]
+	size := int16(state.size * 10.0)
+	glyphScale := font.getPixelHeightScale(float32(size) / 10.0)
+	scale := font.getPixelHeightScale(state.size)
+	advances := make([]float32, len(runes))
+	prevIndex := -1
+	var pen float32
+	for i, codePoint := range runes {
+		index := font.getGlyphIndex(codePoint)
+		if codePoint == '\t' && len(state.tabs) > 0 {
+			advances[i] = nextTabStop(state.tabs, state.tabUnit, pen) - pen
+		} else {
+			if prevIndex != -1 {
+				adv := float32(font.getGlyphKernAdvance(prevIndex, index))*scale + state.spacing
+				if !stash.noSnapX {
+					adv = float32(int(adv + 0.5))
+				}
+				advances[i-1] += adv
+				pen += adv
+			}
+			hAdvance, _ := font.font.GetGlyphHMetrics(index)
+			// Same precision as Glyph.xAdv.
+			xAdv := float32(int16(glyphScale*float32(hAdvance)*10.0)) / 10.0
+			if !stash.noSnapX {
+				xAdv = float32(int(xAdv + 0.5))
+			}
+			advances[i] += xAdv
+		}
+		pen += advances[i]
+		prevIndex = index
+	}
+	return advances
+}
+
+// HasGlyph returns whether the current font has a glyph for the code point, instead of the missing glyph (.notdef).
+func (stash *FontStash) HasGlyph(codePoint rune) bool {
+	state := stash.state
+	if len(stash.fonts) < state.font+1 || state.font < 0 {
+		return false
+	}
+	return stash.fonts[state.font].getGlyphIndex(codePoint) != 0
+}
+
+// GlyphBitmap rasterizes a glyph of current font, size and blur into new alpha buffer.
+// The shared atlas is not touched. The bitmap has one pixel empty border plus blur padding.
+func (stash *FontStash) GlyphBitmap(codePoint rune) (pix []byte, w, h, advance int, ok bool) {
+	state := stash.state
+	if len(stash.fonts) < state.font+1 || state.font < 0 || state.size <= 0 {
+		return nil, 0, 0, 0, false
+	}
+	font := stash.fonts[state.font]
+	blur := int(state.blur)
+	if blur > 20 {
+		blur = 20
+	}
+	pad := blur + 2
+	size := int16(state.size * 10.0)
+	scale := font.getPixelHeightScale(float32(size) / 10.0)
+	index := font.getGlyphIndex(codePoint)
+	adv, _, x0, y0, x1, y1 := font.buildGlyphBitmap(index, scale)
+	w = x1 - x0 + pad*2
+	h = y1 - y0 + pad*2
+	pix = make([]byte, w*h)
+	font.renderGlyphBitmap(pix, pad, pad, x1-x0, y1-y0, w, scale, scale, index)
+	if blur > 0 {
+		stash.blur(pix, w, 0, 0, w, h, blur)
+	}
+	advance = int(scale*float32(adv) + 0.5)
+	return pix, w, h, advance, true
+}
+
 func (stash *FontStash) getQuad(font *Font, prevGlyphIndex int, glyph *Glyph, scale, spacing float32, originalX, originalY float32) (quad Quad, x, y float32) {
 	x = originalX
 	y = originalY
 	if prevGlyphIndex != -1 {
 		adv := float32(font.getGlyphKernAdvance(prevGlyphIndex, glyph.Index)) * scale
-		x += float32(int(adv + spacing + 0.5))
+		if stash.noSnapX {
+			x += adv + spacing
+		} else {
+			x += float32(int(adv + spacing + 0.5))
+		}
 	}
 	xOff := float32(int(glyph.xOff + 1))
 	yOff := float32(int(glyph.yOff + 1))
@@ -512,6 +724,12 @@ func (stash *FontStash) getQuad(font *Font, prevGlyphIndex int, glyph *Glyph, sc
 	// only support FONS_ZERO_TOPLEFT
 	rx := float32(int(x + xOff))
 	ry := float32(int(y + yOff))
+	if stash.noSnapX {
+		rx = x + xOff
+	}
+	if stash.noSnapY {
+		ry = y + yOff
+	}
 
 	quad = Quad{
 		X0: rx,
@@ -523,7 +741,11 @@ func (stash *FontStash) getQuad(font *Font, prevGlyphIndex int, glyph *Glyph, sc
 		S1: x1 * stash.itw,
 		T1: y1 * stash.ith,
 	}
-	x += float32(int(float32(glyph.xAdv)/10.0 + 0.5))
+	if stash.noSnapX {
+		x += float32(glyph.xAdv) / 10.0
+	} else {
+		x += float32(int(float32(glyph.xAdv)/10.0 + 0.5))
+	}
 	return
 }
 
@@ -532,11 +754,9 @@ const (
 	ZPREC = 7
 )
 
-func (stash *FontStash) blurCols(x0, y0, w, h, alpha int) {
+func (stash *FontStash) blurCols(texture []byte, textureWidth, x0, y0, w, h, alpha int) {
 	b := y0 + h
 	r := x0 + w
-	texture := stash.textureData
-	textureWidth := stash.params.width
 	for y := y0; y < b; y++ {
 		z := 0 // force zero border
 		yOffset := y * textureWidth
@@ -556,11 +776,9 @@ func (stash *FontStash) blurCols(x0, y0, w, h, alpha int) {
 	}
 }
 
-func (stash *FontStash) blurRows(x0, y0, w, h, alpha int) {
+func (stash *FontStash) blurRows(texture []byte, textureWidth, x0, y0, w, h, alpha int) {
 	b := y0 + h
 	r := x0 + w
-	texture := stash.textureData
-	textureWidth := stash.params.width
 	for x := x0; x < r; x++ {
 		z := 0 // force zero border
 		for y := 1 + y0; y < b; y++ {
@@ -579,13 +797,13 @@ func (stash *FontStash) blurRows(x0, y0, w, h, alpha int) {
 	}
 }
 
-func (stash *FontStash) blur(x, y, width, height, blur int) {
+func (stash *FontStash) blur(texture []byte, textureWidth, x, y, width, height, blur int) {
 	sigma := float64(blur) * 0.57735 // 1 / sqrt(3)
 	alpha := int(float64(1<<APREC) * (1.0 - math.Exp(-2.3/(sigma+1.0))))
-	stash.blurRows(x, y, width, height, alpha)
-	stash.blurCols(x, y, width, height, alpha)
-	stash.blurRows(x, y, width, height, alpha)
-	stash.blurCols(x, y, width, height, alpha)
+	stash.blurRows(texture, textureWidth, x, y, width, height, alpha)
+	stash.blurCols(texture, textureWidth, x, y, width, height, alpha)
+	stash.blurRows(texture, textureWidth, x, y, width, height, alpha)
+	stash.blurCols(texture, textureWidth, x, y, width, height, alpha)
 }
 
 func fons__maxi(a, b int) int {
@@ -15,6 +15,7 @@ type FontInfo struct {
 	hhea             int
 	hmtx             int
 	kern             int
+	os2              int
 	numGlyphs        int // number of glyphs, needed for range checking
 	indexMap         int // a cmap mapping for our chosen character encoding
 	indexToLocFormat int // format needed to map from glyph index to glyph
@@ -66,6 +67,7 @@ func InitFont(data []byte, offset int) (font *FontInfo, err error) {
 	font.hhea = findTable(data, offset, "hhea")
 	font.hmtx = findTable(data, offset, "hmtx")
 	font.kern = findTable(data, offset, "kern")
+	font.os2 = findTable(data, offset, "OS/2")
 	if cmap == 0 || font.loca == 0 || font.head == 0 || font.glyf == 0 || font.hhea == 0 || font.hmtx == 0 {
 		err = errors.New("Required table not found")
 		return
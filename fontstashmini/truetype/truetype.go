@@ -63,6 +63,28 @@ func (font *FontInfo) GetFontVMetrics() (int, int, int) {
 	return int(int16(u16(font.data, font.hhea+4))), int(int16(u16(font.data, font.hhea+6))), int(int16(u16(font.data, font.hhea+8)))
 }
 
+// GetFontCapAndXHeight returns the height of capital letters and lowercase letters over the baseline
+// in font units. They are read from the OS/2 table (version 2 and later), or measured on the boxes of
+// the glyphs 'H' and 'x' for older fonts.
+func (font *FontInfo) GetFontCapAndXHeight() (int, int) {
+	var capHeight, xHeight int
+	if font.os2 != 0 && font.os2+90 <= len(font.data) && u16(font.data, font.os2) >= 2 {
+		xHeight = int(int16(u16(font.data, font.os2+86)))
+		capHeight = int(int16(u16(font.data, font.os2+88)))
+	}
+	if capHeight <= 0 {
+		if ok, _, _, _, y1 := font.GetGlyphBox(font.FindGlyphIndex('H')); ok {
+			capHeight = y1
+		}
+	}
+	if xHeight <= 0 {
+		if ok, _, _, _, y1 := font.GetGlyphBox(font.FindGlyphIndex('x')); ok {
+			xHeight = y1
+		}
+	}
+	return capHeight, xHeight
+}
+
 func (font *FontInfo) GetGlyphHMetrics(glyphIndex int) (int, int) {
 	numOfLongHorMetrics := int(u16(font.data, font.hhea+34))
 	if glyphIndex < numOfLongHorMetrics {
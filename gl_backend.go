@@ -12,6 +12,9 @@ const (
 	glnvgLocVIEWSIZE = iota
 	glnvgLocTEX
 	glnvgLocFRAG
+	glnvgLocPALETTE
+	glnvgLocMASK
+	glnvgLocPREMULTIPLIED
 	glnvgMaxLOCS
 )
 
@@ -26,6 +29,18 @@ func NewContext(flags CreateFlags) (*Context, error) {
 	return createInternal(params)
 }
 
+// NewContextWithOptions makes new NanoVGo context like NewContext() with the flags and font atlas sizes of options.
+// It returns an error if the options are invalid.
+func NewContextWithOptions(options ContextOptions) (*Context, error) {
+	params := &glParams{
+		isEdgeAntiAlias: (options.Flags & AntiAlias) != 0,
+		context: &glContext{
+			flags: options.Flags,
+		},
+	}
+	return createInternalWithOptions(params, options)
+}
+
 type glShader struct {
 	program      gl.Program
 	fragment     gl.Shader
@@ -33,6 +48,7 @@ type glShader struct {
 	locations    [glnvgMaxLOCS]gl.Uniform
 	vertexAttrib gl.Attrib
 	tcoordAttrib gl.Attrib
+	colorAttrib  gl.Attrib
 }
 
 func (s *glShader) createShader(name, header, opts, vShader, fShader string) error {
@@ -65,6 +81,7 @@ func (s *glShader) createShader(name, header, opts, vShader, fShader string) err
 
 	s.vertexAttrib = gl.GetAttribLocation(program, "vertex")
 	s.tcoordAttrib = gl.GetAttribLocation(program, "tcoord")
+	s.colorAttrib = gl.GetAttribLocation(program, "vcolor")
 
 	s.program = program
 	s.vertex = vertexShader
@@ -89,10 +106,15 @@ func (s *glShader) getUniforms() {
 	s.locations[glnvgLocVIEWSIZE] = gl.GetUniformLocation(s.program, "viewSize")
 	s.locations[glnvgLocTEX] = gl.GetUniformLocation(s.program, "tex")
 	s.locations[glnvgLocFRAG] = gl.GetUniformLocation(s.program, "frag")
+	s.locations[glnvgLocPALETTE] = gl.GetUniformLocation(s.program, "palette")
+	s.locations[glnvgLocMASK] = gl.GetUniformLocation(s.program, "mask")
+	s.locations[glnvgLocPREMULTIPLIED] = gl.GetUniformLocation(s.program, "premultipliedOutput")
 }
 
 const (
 	glnvgGLUniformArraySize = 11
+	// glnvgBlurTaps is the number of taps on each side of the blur kernel, which must match the shader.
+	glnvgBlurTaps = 16
 )
 
 const (
@@ -106,12 +128,18 @@ type glContext struct {
 	textures     []*glTexture
 	textureID    int
 	vertexBuffer gl.Buffer
+	colorBuffer  gl.Buffer
 	flags        CreateFlags
 	calls        []glCall
 	paths        []glPath
 	vertexes     []float32
+	colors       []float32 // premultiplied RGBA per vertex, only up to the last vertex with color
 	uniforms     []glFragUniforms
 
+	strokeOverlap OverlapMode
+	blurTextures  [2]gl.Texture
+	premultiplied bool
+
 	stencilMask     uint32
 	stencilFunc     gl.Enum
 	stencilFuncRef  int
@@ -131,6 +159,10 @@ func (c *glContext) deleteTexture(id int) error {
 	tex := c.findTexture(id)
 	if tex != nil && (tex.flags&ImageNoDelete) == 0 {
 		gl.DeleteTexture(tex.tex)
+		if tex.palette.Valid() {
+			gl.DeleteTexture(tex.palette)
+			tex.palette = gl.Texture{}
+		}
 		tex.id = 0
 		return nil
 	}
@@ -177,6 +209,17 @@ func (c *glContext) allocVertexMemory(size int) int {
 	return offset
 }
 
+// setVertexColors sets the colors of vertexes from offset, which was returned by allocVertexMemory().
+func (c *glContext) setVertexColors(offset int, colors []Color) {
+	if len(c.colors) < offset {
+		c.colors = append(c.colors, make([]float32, offset-len(c.colors))...)
+	}
+	c.colors = c.colors[:offset]
+	for _, color := range colors {
+		c.colors = append(c.colors, color.PreMultiply().List()...)
+	}
+}
+
 func (c *glContext) allocFragUniforms(n int) ([]glFragUniforms, int) {
 	ret := len(c.uniforms)
 	c.uniforms = append(c.uniforms, make([]glFragUniforms, n)...)
@@ -218,9 +261,14 @@ func (c *glContext) convertPaint(frag *glFragUniforms, paint *Paint, scissor *nv
 		xform := &scissor.xform
 		frag.setScissorMat(xform.Inverse().ToMat3x4())
 		frag.setScissorExt(scissor.extent[0], scissor.extent[1])
-		scaleX := sqrtF(xform[0]*xform[0]+xform[2]*xform[2]) / fringe
-		scaleY := sqrtF(xform[1]*xform[1]+xform[3]*xform[3]) / fringe
+		edge := fringe
+		if scissor.feather > 0 {
+			edge = scissor.feather
+		}
+		scaleX := sqrtF(xform[0]*xform[0]+xform[2]*xform[2]) / edge
+		scaleY := sqrtF(xform[1]*xform[1]+xform[3]*xform[3]) / edge
 		frag.setScissorScale(scaleX, scaleY)
+		frag.setScissorFeather(scissor.feather > 0)
 	}
 	frag.setExtent(paint.extent)
 	frag.setStrokeMult((width*0.5 + fringe*0.5) / fringe)
@@ -238,7 +286,9 @@ func (c *glContext) convertPaint(frag *glFragUniforms, paint *Paint, scissor *nv
 		}
 		frag.setType(nsvgShaderFILLIMG)
 
-		if tex.texType == nvgTextureRGBA {
+		if tex.palette.Valid() {
+			frag.setTexType(3)
+		} else if tex.texType == nvgTextureRGBA {
 			if tex.flags&ImagePreMultiplied != 0 {
 				frag.setTexType(0)
 			} else {
@@ -252,6 +302,9 @@ func (c *glContext) convertPaint(frag *glFragUniforms, paint *Paint, scissor *nv
 		frag.setRadius(paint.radius)
 		frag.setFeather(paint.feather)
 		frag.setPaintMat(paint.xform.Inverse().ToMat3x4())
+		frag.setRadiusY(paint.radiusY)
+		frag.setFeatherY(paint.featherY)
+		frag.setGradientRepeat(paint.repeat)
 	}
 
 	return nil
@@ -262,7 +315,13 @@ func (c *glContext) setUniforms(uniformOffset, image int) {
 	gl.Uniform4fv(c.shader.locations[glnvgLocFRAG], frag[:])
 
 	if image != 0 {
-		c.bindTexture(&c.findTexture(image).tex)
+		tex := c.findTexture(image)
+		if tex.palette.Valid() {
+			gl.ActiveTexture(gl.TEXTURE1)
+			c.bindTexture(&tex.palette)
+			gl.ActiveTexture(gl.TEXTURE0)
+		}
+		c.bindTexture(&tex.tex)
 		checkError(c, "tex paint tex")
 	} else {
 		c.bindTexture(&gl.Texture{})
@@ -336,7 +395,7 @@ func (c *glContext) convexFill(call *glCall) {
 func (c *glContext) stroke(call *glCall) {
 	paths := c.paths[call.pathOffset : call.pathOffset+call.pathCount]
 
-	if c.flags&StencilStrokes != 0 {
+	if call.stencilStroke {
 		gl.Enable(gl.STENCIL_TEST)
 		c.setStencilMask(0xff)
 
@@ -387,11 +446,77 @@ func (c *glContext) triangles(call *glCall) {
 }
 
 func (c *glContext) triangleStrip(call *glCall) {
+	if call.mask != 0 {
+		gl.ActiveTexture(gl.TEXTURE2)
+		c.bindTexture(&c.findTexture(call.mask).tex)
+		gl.ActiveTexture(gl.TEXTURE0)
+	}
 	c.setUniforms(call.uniformOffset, call.image)
 	checkError(c, "triangle strip fill")
 	gl.DrawArrays(gl.TRIANGLE_STRIP, call.triangleOffset, call.triangleCount)
 }
 
+func (c *glContext) blur(call *glCall) {
+	var viewport [4]int32
+	gl.GetIntegerv(gl.VIEWPORT, viewport[:])
+	sx := float32(viewport[2]) / c.view[0]
+	sy := float32(viewport[3]) / c.view[1]
+
+	// The box around the quad is the last 4 vertexes: top-right, top-left, bottom-right, bottom-left.
+	boxOffset := call.triangleOffset + call.triangleCount - 4
+	box := c.vertexes[boxOffset*4:]
+	x0 := int(floorF(box[4] * sx))
+	x1 := int(ceilF(box[0] * sx))
+	// Framebuffer rows start from the bottom.
+	y0 := int(floorF((c.view[1] - box[9]) * sy))
+	y1 := int(ceilF((c.view[1] - box[5]) * sy))
+	w := x1 - x0
+	h := y1 - y0
+	if w <= 0 || h <= 0 {
+		return
+	}
+	// Maps window coordinates to texture coordinates of the captured box.
+	mat := TransformMatrix{sx / float32(w), 0, 0, -sy / float32(h), -float32(x0) / float32(w), (c.view[1]*sy - float32(y0)) / float32(h)}
+	sigma := call.blurRadius * sx
+	step := maxF(1, sigma*3/glnvgBlurTaps)
+
+	for i := range c.blurTextures {
+		if !c.blurTextures[i].Valid() {
+			c.blurTextures[i] = gl.CreateTexture()
+			c.bindTexture(&c.blurTextures[i])
+			gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+			gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+			gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+			gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+		}
+	}
+	gl.Disable(gl.STENCIL_TEST)
+	gl.Disable(gl.BLEND)
+
+	// Keep the original pixels, blur them horizontally over the box and then vertically into the quad.
+	// The box is restored in between, so only the quad changes.
+	c.bindTexture(&c.blurTextures[0])
+	gl.CopyTexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, int(viewport[0])+x0, int(viewport[1])+y0, w, h, 0)
+	c.blurPass(call.uniformOffset, &c.blurTextures[0], mat, step/float32(w), 0, sigma/step, boxOffset, 4)
+	c.bindTexture(&c.blurTextures[1])
+	gl.CopyTexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, int(viewport[0])+x0, int(viewport[1])+y0, w, h, 0)
+	c.blurPass(call.uniformOffset, &c.blurTextures[0], mat, 0, 0, 0, boxOffset, 4)
+	c.blurPass(call.uniformOffset+1, &c.blurTextures[1], mat, 0, step/float32(h), sigma/step, call.triangleOffset, call.triangleCount-4)
+
+	gl.Enable(gl.BLEND)
+	checkError(c, "blur")
+}
+
+func (c *glContext) blurPass(uniformOffset int, tex *gl.Texture, mat TransformMatrix, stepX, stepY, sigma float32, offset, count int) {
+	frag := &c.uniforms[uniformOffset]
+	frag.setPaintMat(mat.ToMat3x4())
+	frag.setExtent([2]float32{stepX, stepY})
+	frag.setRadius(sigma)
+	gl.Uniform4fv(c.shader.locations[glnvgLocFRAG], frag[:])
+	c.bindTexture(tex)
+	gl.DrawArrays(gl.TRIANGLE_STRIP, offset, count)
+}
+
 type glParams struct {
 	isEdgeAntiAlias bool
 	context         *glContext
@@ -401,6 +526,17 @@ func (p *glParams) edgeAntiAlias() bool {
 	return p.isEdgeAntiAlias
 }
 
+func (p *glParams) renderCapabilities() Capabilities {
+	// The font atlas is an alpha texture, glyphs are always tinted with the fill paint.
+	// Colors are written premultiplied or straight, see setPremultipliedOutput.
+	// Pixels can't be read back and framebuffers can't be used through the context yet.
+	return Capabilities{
+		StencilClip:   true,
+		VertexColors:  true,
+		Premultiplied: true,
+	}
+}
+
 func (p *glParams) renderCreate() error {
 	context := p.context
 	//align := 4
@@ -422,7 +558,7 @@ func (p *glParams) renderCreate() error {
 	context.shader.getUniforms()
 
 	context.vertexBuffer = gl.CreateBuffer()
-	context.vertexBuffer = gl.CreateBuffer()
+	context.colorBuffer = gl.CreateBuffer()
 
 	checkError(context, "create done")
 	gl.Finish()
@@ -459,11 +595,21 @@ func (p *glParams) renderCreateTexture(texType nvgTextureType, w, h int, flags I
 	}
 
 	if (flags & ImageGenerateMipmaps) != 0 {
-		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+		if (flags & ImageNearest) != 0 {
+			gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST_MIPMAP_NEAREST)
+		} else {
+			gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+		}
+	} else if (flags & ImageNearest) != 0 {
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
 	} else {
 		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
 	}
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	if (flags & ImageNearest) != 0 {
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	} else {
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	}
 
 	if (flags & ImageRepeatX) != 0 {
 		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.REPEAT)
@@ -493,6 +639,10 @@ func (p *glParams) renderDeleteTexture(id int) error {
 	tex := p.context.findTexture(id)
 	if tex.tex.Valid() && (tex.flags&ImageNoDelete) == 0 {
 		gl.DeleteTexture(tex.tex)
+		if tex.palette.Valid() {
+			gl.DeleteTexture(tex.palette)
+			tex.palette = gl.Texture{}
+		}
 		tex.id = 0
 		tex.tex = gl.Texture{}
 		return nil
@@ -529,6 +679,36 @@ func (p *glParams) renderUpdateTexture(image, x, y, w, h int, data []byte) error
 	return nil
 }
 
+func (p *glParams) renderSetTexturePalette(image int, palette []Color) error {
+	tex := p.context.findTexture(image)
+	if tex == nil || tex.texType != nvgTextureALPHA {
+		return errors.New("invalid texture in GLParams.setTexturePalette")
+	}
+	data := paletteData(palette)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	if !tex.palette.Valid() {
+		// Indices must not be interpolated.
+		p.context.bindTexture(&tex.tex)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+
+		tex.palette = gl.CreateTexture()
+		p.context.bindTexture(&tex.palette)
+		gl.TexImage2D(gl.TEXTURE_2D, 0, 256, 1, gl.RGBA, gl.UNSIGNED_BYTE, data)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	} else {
+		p.context.bindTexture(&tex.palette)
+		gl.TexSubImage2D(gl.TEXTURE_2D, 0, 0, 0, 256, 1, gl.RGBA, gl.UNSIGNED_BYTE, data)
+	}
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 4)
+	p.context.checkError("set palette")
+	p.context.bindTexture(nil)
+	return nil
+}
+
 func (p *glParams) renderGetTextureSize(image int) (int, int, error) {
 	tex := p.context.findTexture(image)
 	if tex == nil {
@@ -545,6 +725,7 @@ func (p *glParams) renderViewport(width, height int) {
 func (p *glParams) renderCancel() {
 	c := p.context
 	c.vertexes = c.vertexes[:0]
+	c.colors = c.colors[:0]
 	c.paths = c.paths[:0]
 	c.calls = c.calls[:0]
 	c.uniforms = c.uniforms[:0]
@@ -556,7 +737,12 @@ func (p *glParams) renderFlush() {
 	if len(c.calls) > 0 {
 		gl.UseProgram(c.shader.program)
 
-		gl.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+		// The shader computes premultiplied colors, straight output divides them by alpha again.
+		if c.premultiplied {
+			gl.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+		} else {
+			gl.BlendFuncSeparate(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA, gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+		}
 		gl.Enable(gl.CULL_FACE)
 		gl.CullFace(gl.BACK)
 		gl.FrontFace(gl.CCW)
@@ -582,10 +768,25 @@ func (p *glParams) renderFlush() {
 		gl.EnableVertexAttribArray(c.shader.tcoordAttrib)
 		gl.VertexAttribPointer(c.shader.vertexAttrib, 2, gl.FLOAT, false, 4*4, 0)
 		gl.VertexAttribPointer(c.shader.tcoordAttrib, 2, gl.FLOAT, false, 4*4, 8)
+		if len(c.colors) > 0 {
+			// Vertex colors are only used by vertex color calls, the other vertexes get zeros.
+			c.colors = append(c.colors, make([]float32, len(c.vertexes)-len(c.colors))...)
+			gl.BindBuffer(gl.ARRAY_BUFFER, c.colorBuffer)
+			gl.BufferData(gl.ARRAY_BUFFER, castFloat32ToByte(c.colors), gl.STREAM_DRAW)
+			gl.EnableVertexAttribArray(c.shader.colorAttrib)
+			gl.VertexAttribPointer(c.shader.colorAttrib, 4, gl.FLOAT, false, 4*4, 0)
+		}
 
 		// Set view and texture just once per frame.
 		gl.Uniform1i(c.shader.locations[glnvgLocTEX], 0)
+		gl.Uniform1i(c.shader.locations[glnvgLocPALETTE], 1)
+		gl.Uniform1i(c.shader.locations[glnvgLocMASK], 2)
 		gl.Uniform2fv(c.shader.locations[glnvgLocVIEWSIZE], c.view[:])
+		if c.premultiplied {
+			gl.Uniform1f(c.shader.locations[glnvgLocPREMULTIPLIED], 1)
+		} else {
+			gl.Uniform1f(c.shader.locations[glnvgLocPREMULTIPLIED], 0)
+		}
 
 		for i := range c.calls {
 			call := &c.calls[i]
@@ -600,16 +801,22 @@ func (p *glParams) renderFlush() {
 				c.triangles(call)
 			case glnvgTRIANGLESTRIP:
 				c.triangleStrip(call)
+			case glnvgBLUR:
+				c.blur(call)
 			}
 		}
 		gl.DisableVertexAttribArray(c.shader.vertexAttrib)
 		gl.DisableVertexAttribArray(c.shader.tcoordAttrib)
+		if len(c.colors) > 0 {
+			gl.DisableVertexAttribArray(c.shader.colorAttrib)
+		}
 		gl.Disable(gl.CULL_FACE)
 		gl.BindBuffer(gl.ARRAY_BUFFER, gl.Buffer{})
 		gl.UseProgram(gl.Program{})
 		c.bindTexture(nil)
 	}
 	c.vertexes = c.vertexes[:0]
+	c.colors = c.colors[:0]
 	c.paths = c.paths[:0]
 	c.calls = c.calls[:0]
 	c.uniforms = c.uniforms[:0]
@@ -625,7 +832,7 @@ func (p *glParams) renderFill(paint *Paint, scissor *nvgScissor, fringe float32,
 	call := &c.calls[len(c.calls)-1]
 	glPaths, call.pathOffset = c.allocPath(call.pathCount)
 
-	if len(paths) == 0 && paths[0].convex {
+	if len(paths) == 1 && paths[0].convex {
 		call.callType = glnvgCONVEXFILL
 	} else {
 		call.callType = glnvgFILL
@@ -732,12 +939,21 @@ func (p *glParams) renderFill(paint *Paint, scissor *nvgScissor, fringe float32,
 	c.convertPaint(paintFrag, paint, scissor, fringe, fringe, -1.0)
 }
 
+func (p *glParams) setStrokeOverlapMode(mode OverlapMode) {
+	p.context.strokeOverlap = mode
+}
+
+func (p *glParams) setPremultipliedOutput(enabled bool) {
+	p.context.premultiplied = enabled
+}
+
 func (p *glParams) renderStroke(paint *Paint, scissor *nvgScissor, fringe float32, strokeWidth float32, paths []nvgPath) {
 	c := p.context
 	var glPaths []glPath
 	p.context.calls = append(c.calls, glCall{})
 	call := &c.calls[len(c.calls)-1]
 	call.callType = glnvgSTROKE
+	call.stencilStroke = c.flags&StencilStrokes != 0 || c.strokeOverlap == OverlapUnified
 	glPaths, call.pathOffset = c.allocPath(len(paths))
 	call.pathCount = len(paths)
 	call.image = paint.image
@@ -768,7 +984,7 @@ func (p *glParams) renderStroke(paint *Paint, scissor *nvgScissor, fringe float3
 	}
 
 	// Fill shader
-	if c.flags&StencilStrokes != 0 {
+	if call.stencilStroke {
 		var uniforms []glFragUniforms
 		uniforms, call.uniformOffset = c.allocFragUniforms(2)
 		u0 := &uniforms[0]
@@ -852,16 +1068,148 @@ func (p *glParams) renderTriangleStrip(paint *Paint, scissor *nvgScissor, vertex
 	f0.setType(nsvgShaderIMG)
 }
 
+func (p *glParams) renderTriangleStripColors(scissor *nvgScissor, vertexes []nvgVertex, colors []Color) {
+	c := p.context
+
+	vertexCount := len(vertexes)
+	vertexOffset := c.allocVertexMemory(vertexCount)
+	callIndex := len(c.calls)
+
+	c.calls = append(c.calls, glCall{
+		callType:       glnvgTRIANGLESTRIP,
+		triangleOffset: vertexOffset / 4,
+		triangleCount:  vertexCount,
+	})
+	call := &c.calls[callIndex]
+	c.setVertexColors(vertexOffset, colors)
+
+	for i := 0; i < vertexCount; i++ {
+		vertex := &vertexes[i]
+		c.vertexes[vertexOffset] = vertex.x
+		c.vertexes[vertexOffset+1] = vertex.y
+		c.vertexes[vertexOffset+2] = vertex.u
+		c.vertexes[vertexOffset+3] = vertex.v
+		vertexOffset += 4
+	}
+
+	// Fill shader
+	var frags []glFragUniforms
+	frags, call.uniformOffset = c.allocFragUniforms(1)
+	f0 := &frags[0]
+	f0.reset()
+	var paint Paint
+	c.convertPaint(f0, &paint, scissor, 1.0, 1.0, -1.0)
+	f0.setType(nsvgShaderVERTCOLOR)
+}
+
+func (p *glParams) renderTextPaint(paint *Paint, scissor *nvgScissor, fontImage int, vertexes []nvgVertex) {
+	c := p.context
+	if c.findTexture(fontImage) == nil {
+		return
+	}
+
+	vertexCount := len(vertexes)
+	vertexOffset := c.allocVertexMemory(vertexCount)
+	callIndex := len(c.calls)
+
+	c.calls = append(c.calls, glCall{
+		callType:       glnvgTRIANGLESTRIP,
+		image:          paint.image,
+		mask:           fontImage,
+		triangleOffset: vertexOffset / 4,
+		triangleCount:  vertexCount,
+	})
+	call := &c.calls[callIndex]
+
+	for i := 0; i < vertexCount; i++ {
+		vertex := &vertexes[i]
+		c.vertexes[vertexOffset] = vertex.x
+		c.vertexes[vertexOffset+1] = vertex.y
+		c.vertexes[vertexOffset+2] = vertex.u
+		c.vertexes[vertexOffset+3] = vertex.v
+		vertexOffset += 4
+	}
+
+	// Gradient or image shader masked by the glyph coverage
+	var frags []glFragUniforms
+	frags, call.uniformOffset = c.allocFragUniforms(1)
+	f0 := &frags[0]
+	f0.reset()
+	c.convertPaint(f0, paint, scissor, 1.0, 1.0, -1.0)
+	f0.setTextMask(true)
+}
+
+// renderBlurRegion blurs the rendered pixels under the quad given as triangle strip in device space.
+// radius is the standard deviation of the gaussian.
+func (p *glParams) renderBlurRegion(scissor *nvgScissor, vertexes []nvgVertex, radius float32) {
+	c := p.context
+	// Pixels around the quad are captured too, so that the blur near its edges samples them.
+	pad := radius * 3
+	minX, minY, maxX, maxY := c.view[0], c.view[1], float32(0), float32(0)
+	for _, v := range vertexes {
+		minX = minF(minX, v.x)
+		minY = minF(minY, v.y)
+		maxX = maxF(maxX, v.x)
+		maxY = maxF(maxY, v.y)
+	}
+	minX = maxF(minX-pad, 0)
+	minY = maxF(minY-pad, 0)
+	maxX = minF(maxX+pad, c.view[0])
+	maxY = minF(maxY+pad, c.view[1])
+	if maxX <= minX || maxY <= minY {
+		return
+	}
+	box := []nvgVertex{{x: maxX, y: minY}, {x: minX, y: minY}, {x: maxX, y: maxY}, {x: minX, y: maxY}}
+
+	vertexCount := len(vertexes) + len(box)
+	vertexOffset := c.allocVertexMemory(vertexCount)
+	callIndex := len(c.calls)
+	c.calls = append(c.calls, glCall{
+		callType:       glnvgBLUR,
+		triangleOffset: vertexOffset / 4,
+		triangleCount:  vertexCount,
+		blurRadius:     radius,
+	})
+	call := &c.calls[callIndex]
+	for _, vertex := range append(append(make([]nvgVertex, 0, vertexCount), vertexes...), box...) {
+		c.vertexes[vertexOffset] = vertex.x
+		c.vertexes[vertexOffset+1] = vertex.y
+		vertexOffset += 4
+	}
+
+	// Paint matrix and blur parameters are set at flush, when the framebuffer size is known.
+	var frags []glFragUniforms
+	frags, call.uniformOffset = c.allocFragUniforms(2)
+	var paint Paint
+	noScissor := nvgScissor{extent: [2]float32{-1.0, -1.0}}
+	for i, s := range []*nvgScissor{&noScissor, scissor} {
+		frags[i].reset()
+		c.convertPaint(&frags[i], &paint, s, 1.0, 1.0, -1.0)
+		frags[i].setType(nsvgShaderBLUR)
+	}
+}
+
 func (p *glParams) renderDelete() {
 	c := p.context
 	c.shader.deleteShader()
 	if c.vertexBuffer.Valid() {
 		gl.DeleteBuffer(c.vertexBuffer)
 	}
+	if c.colorBuffer.Valid() {
+		gl.DeleteBuffer(c.colorBuffer)
+	}
 	for _, texture := range c.textures {
 		if texture.tex.Valid() && (texture.flags&ImageNoDelete) == 0 {
 			gl.DeleteTexture(texture.tex)
 		}
+		if texture.palette.Valid() {
+			gl.DeleteTexture(texture.palette)
+		}
+	}
+	for _, texture := range c.blurTextures {
+		if texture.Valid() {
+			gl.DeleteTexture(texture)
+		}
 	}
 	p.context = nil
 }
@@ -905,18 +1253,23 @@ var fillVertexShader = `
    uniform vec2 viewSize;
    in vec2 vertex;
    in vec2 tcoord;
+   in vec4 vcolor;
    out vec2 ftcoord;
    out vec2 fpos;
+   out vec4 fcolor;
 #else
    uniform vec2 viewSize;
    attribute vec2 vertex;
    attribute vec2 tcoord;
+   attribute vec4 vcolor;
    varying vec2 ftcoord;
    varying vec2 fpos;
+   varying vec4 fcolor;
 #endif
 void main(void) {
    ftcoord = tcoord;
    fpos = vertex;
+   fcolor = vcolor;
    gl_Position = vec4(2.0*vertex.x/viewSize.x - 1.0, 1.0 - 2.0*vertex.y/viewSize.y, 0, 1);
 }`
 
@@ -945,20 +1298,33 @@ var fillFragmentShader = `
                int texType;
                int type;
        };
+       #define radiusY radius
+       #define featherY feather
+       #define gradientRepeat 0.0
+       #define scissorFeather 0.0
+       #define textMask 0.0
 #else
        // NANOVG_GL3 && !USE_UNIFORMBUF
        uniform vec4 frag[UNIFORMARRAY_SIZE];
 #endif
        uniform sampler2D tex;
+       uniform sampler2D palette;
+       uniform sampler2D mask;
+       uniform float premultipliedOutput;
        in vec2 ftcoord;
        in vec2 fpos;
+       in vec4 fcolor;
        out vec4 outColor;
 #else
        // !NANOVG_GL3
        uniform vec4 frag[UNIFORMARRAY_SIZE];
        uniform sampler2D tex;
+       uniform sampler2D palette;
+       uniform sampler2D mask;
+       uniform float premultipliedOutput;
        varying vec2 ftcoord;
        varying vec2 fpos;
+       varying vec4 fcolor;
 #endif
 #ifndef USE_UNIFORMBUFFER
        #define scissorMat mat3(frag[0].xyz, frag[1].xyz, frag[2].xyz)
@@ -970,27 +1336,67 @@ var fillFragmentShader = `
        #define extent frag[9].xy
        #define radius frag[9].z
        #define feather frag[9].w
+       #define radiusY frag[3].w
+       #define featherY frag[4].w
+       #define gradientRepeat frag[5].w
+       #define scissorFeather frag[0].w
+       #define textMask frag[1].w
        #define strokeMult frag[10].x
        #define strokeThr frag[10].y
        #define texType int(frag[10].z)
        #define type int(frag[10].w)
 #endif
 
-float sdroundrect(vec2 pt, vec2 ext, float rad) {
-       vec2 ext2 = ext - vec2(rad,rad);
-       vec2 d = abs(pt) - ext2;
-       return min(max(d.x,d.y),0.0) + length(max(d,0.0)) - rad;
+// Distance to rounded rect with elliptic corners, in feather units.
+float sdroundrect(vec2 pt, vec2 ext, vec2 rad, vec2 f) {
+       vec2 r = rad / f;
+       if (abs(r.x - r.y) < 0.001) {
+               vec2 d = abs(pt) / f - (ext / f - r);
+               return min(max(d.x,d.y),0.0) + length(max(d,0.0)) - r.x;
+       }
+       // Approximate distance to ellipse.
+       r = max(r, vec2(0.01,0.01));
+       vec2 d = abs(pt) / f - (ext / f - r);
+       vec2 q = max(d,0.0);
+       float k0 = length(q / r);
+       float k1 = length(q / (r*r));
+       float corner = k1 > 0.0 ? k0*(k0-1.0)/k1 : -min(r.x,r.y);
+       return min(max(d.x,d.y),0.0) + corner;
+}
+
+// Color of 8-bit palette index.
+vec4 paletteColor(float index) {
+       vec2 pt = vec2((index*255.0+0.5)/256.0, 0.5);
+#ifdef NANOVG_GL3
+       vec4 color = texture(palette, pt);
+#else
+       vec4 color = texture2D(palette, pt);
+#endif
+       return vec4(color.xyz*color.w,color.w);
+}
+
+vec4 texel(vec2 pt) {
+#ifdef NANOVG_GL3
+       return texture(tex, pt);
+#else
+       return texture2D(tex, pt);
+#endif
 }
 
 // Scissoring
 float scissorMask(vec2 p) {
        vec2 sc = (abs((scissorMat * vec3(p,1.0)).xy) - scissorExt);
        sc = vec2(0.5,0.5) - sc * scissorScale;
+       if (scissorFeather > 0.5) {
+               sc = smoothstep(0.0, 1.0, sc);
+       }
        return clamp(sc.x,0.0,1.0) * clamp(sc.y,0.0,1.0);
 }
 #ifdef EDGE_AA
 // Stroke - from [0..1] to clipped pyramid, where the slope is 1px.
 float strokeMask() {
+       // Text quads have font atlas coordinates instead of stroke coordinates.
+       if (textMask > 0.5) return 1.0;
        return min(1.0, (1.0-abs(ftcoord.x*2.0-1.0))*strokeMult) * min(1.0, ftcoord.y);
 }
 #endif
@@ -1006,7 +1412,9 @@ void main(void) {
        if (type == 0) {                        // Gradient
                // Calculate gradient color using box gradient
                vec2 pt = (paintMat * vec3(fpos,1.0)).xy;
-               float d = clamp((sdroundrect(pt, extent, radius) + feather*0.5) / feather, 0.0, 1.0);
+               float d = sdroundrect(pt, extent, vec2(radius,radiusY), vec2(feather,featherY)) + 0.5;
+               // Repeated gradients wrap around instead of clamping.
+               d = gradientRepeat > 0.5 ? fract(d) : clamp(d, 0.0, 1.0);
                vec4 color = mix(innerCol,outerCol,d);
                // Combine alpha
                color *= strokeAlpha * scissor;
@@ -1021,6 +1429,7 @@ void main(void) {
 #endif
                if (texType == 1) color = vec4(color.xyz*color.w,color.w);
                if (texType == 2) color = vec4(color.x);
+               if (texType == 3) color = paletteColor(color.x);
                // Apply color tint and alpha.
                color *= innerCol;
                // Combine alpha
@@ -1036,12 +1445,41 @@ void main(void) {
 #endif
                if (texType == 1) color = vec4(color.xyz*color.w,color.w);
                if (texType == 2) color = vec4(color.x);
+               if (texType == 3) color = paletteColor(color.x);
                color *= scissor;
                result = color * innerCol;
+       } else if (type == 4) {         // Blur, extent is the step between taps and radius is sigma in steps
+               vec2 pt = (paintMat * vec3(fpos,1.0)).xy;
+               vec4 color = texel(pt);
+               if (radius > 0.0) {
+                       float total = 1.0;
+                       for (int i = 1; i <= 16; i++) {
+                               float t = float(i);
+                               float w = exp(-0.5*t*t/(radius*radius));
+                               color += w * (texel(pt + extent*t) + texel(pt - extent*t));
+                               total += w * 2.0;
+                       }
+                       color /= total;
+               }
+               // Blending is disabled, so the scissor can't be anti-aliased.
+               if (scissor < 0.5) discard;
+               result = color;
+       } else if (type == 5) {         // Vertex colors, premultiplied
+               result = fcolor * strokeAlpha * scissor;
+       }
+       if (textMask > 0.5) {
+               // Glyph coverage of the font atlas.
+#ifdef NANOVG_GL3
+               result *= texture(mask, ftcoord).x;
+#else
+               result *= texture2D(mask, ftcoord).x;
+#endif
        }
 #ifdef EDGE_AA
        if (strokeAlpha < strokeThr) discard;
 #endif
+       // Stencil fills write no color and blur copies the framebuffer as it is.
+       if (premultipliedOutput < 0.5 && type != 2 && type != 4 && result.a > 0.0) result.rgb /= result.a;
 #ifdef NANOVG_GL3
        outColor = result;
 #else
@@ -7,6 +7,8 @@ const (
 	nsvgShaderFILLIMG
 	nsvgShaderSIMPLE
 	nsvgShaderIMG
+	nsvgShaderBLUR
+	nsvgShaderVERTCOLOR
 )
 
 type glnvgCallType int
@@ -18,16 +20,20 @@ const (
 	glnvgSTROKE
 	glnvgTRIANGLES
 	glnvgTRIANGLESTRIP
+	glnvgBLUR
 )
 
 type glCall struct {
 	callType       glnvgCallType
+	stencilStroke  bool
 	image          int
+	mask           int
 	pathOffset     int
 	pathCount      int
 	triangleOffset int
 	triangleCount  int
 	uniformOffset  int
+	blurRadius     float32
 }
 
 type glPath struct {
@@ -49,6 +55,24 @@ func (u *glFragUniforms) setScissorMat(mat []float32) {
 	copy(u[0:12], mat[0:12])
 }
 
+// setScissorFeather uses the padding of scissorMat column.
+func (u *glFragUniforms) setScissorFeather(enable bool) {
+	if enable {
+		u[3] = 1
+	} else {
+		u[3] = 0
+	}
+}
+
+// setTextMask uses the padding of scissorMat column.
+func (u *glFragUniforms) setTextMask(enable bool) {
+	if enable {
+		u[7] = 1
+	} else {
+		u[7] = 0
+	}
+}
+
 func (u *glFragUniforms) clearScissorMat() {
 	for i := 0; i < 12; i++ {
 		u[i] = 0
@@ -89,6 +113,24 @@ func (u *glFragUniforms) setFeather(feather float32) {
 	u[39] = feather
 }
 
+// setRadiusY and setFeatherY use the padding of paintMat columns.
+func (u *glFragUniforms) setRadiusY(radius float32) {
+	u[15] = radius
+}
+
+func (u *glFragUniforms) setFeatherY(feather float32) {
+	u[19] = feather
+}
+
+// setGradientRepeat uses the padding of paintMat column.
+func (u *glFragUniforms) setGradientRepeat(enable bool) {
+	if enable {
+		u[23] = 1
+	} else {
+		u[23] = 0
+	}
+}
+
 func (u *glFragUniforms) setStrokeMult(strokeMult float32) {
 	u[40] = strokeMult
 }
@@ -110,5 +152,6 @@ type glTexture struct {
 	tex           gl.Texture
 	width, height int
 	texType       nvgTextureType
+	palette       gl.Texture
 	flags         ImageFlags
 }
@@ -0,0 +1,203 @@
+package nanovgo
+
+import "sort"
+
+// DrawMetaballs fills the smooth union of the circles (x, y, radius) with color, so that they merge like
+// metaballs where they are closer than about smoothing. Zero smoothing is the plain union of the circles.
+// The outline is the zero level of the smooth minimum of the signed distances of the circles, traced on the
+// CPU on a grid of about 1.5 pixels, so it works with all backends. The current path is replaced.
+func (ctx *Context) DrawMetaballs(circles [][3]float32, smoothing float32, color Color) {
+	if len(circles) == 0 {
+		return
+	}
+	smoothing = maxF(smoothing, 0)
+
+	// The smooth minimum is at most smoothing/4 below the plain one, so the blob can't grow farther.
+	minX, minY, maxX, maxY := circles[0][0], circles[0][1], circles[0][0], circles[0][1]
+	for _, c := range circles {
+		r := absF(c[2])
+		minX, minY = minF(minX, c[0]-r), minF(minY, c[1]-r)
+		maxX, maxY = maxF(maxX, c[0]+r), maxF(maxY, c[1]+r)
+	}
+	if maxX <= minX || maxY <= minY {
+		return
+	}
+	state := ctx.getState()
+	cell := 1.5 / maxF(state.xform.getAverageScale()*ctx.devicePxRatio, 1e-6)
+	cell = maxF(cell, maxF(maxX-minX, maxY-minY)/nvgMaxMetaballCells)
+	// One cell of padding keeps the border of the grid outside, so that the traced outlines are closed.
+	margin := smoothing*0.25 + cell
+	minX, minY = minX-margin, minY-margin
+	gx := int(ceilF((maxX+margin-minX)/cell)) + 1
+	gy := int(ceilF((maxY+margin-minY)/cell)) + 1
+
+	field := make([]float32, gx*gy)
+	for j := 0; j < gy; j++ {
+		for i := 0; i < gx; i++ {
+			field[j*gx+i] = metaballDistance(circles, smoothing, minX+float32(i)*cell, minY+float32(j)*cell)
+		}
+	}
+	loops := traceZeroLevel(field, gx, gy)
+	if len(loops) == 0 {
+		return
+	}
+
+	ctx.Block(func() {
+		ctx.BeginPath()
+		for _, loop := range loops {
+			for k := 0; k < len(loop); k += 2 {
+				x, y := minX+loop[k]*cell, minY+loop[k+1]*cell
+				if k == 0 {
+					ctx.MoveTo(x, y)
+				} else {
+					ctx.LineTo(x, y)
+				}
+			}
+			ctx.ClosePath()
+			// Outlines go clockwise on screen around insides and counterclockwise around holes.
+			if loopArea(loop) > 0 {
+				ctx.PathWinding(Solid)
+			} else {
+				ctx.PathWinding(Hole)
+			}
+		}
+		ctx.SetFillColor(color)
+		ctx.Fill()
+	})
+}
+
+// metaballDistance returns the smooth minimum of the signed distances from (x, y) to the circles.
+func metaballDistance(circles [][3]float32, smoothing, x, y float32) float32 {
+	var d float32
+	for i, c := range circles {
+		dx, dy := x-c[0], y-c[1]
+		di := sqrtF(dx*dx+dy*dy) - absF(c[2])
+		switch {
+		case i == 0:
+			d = di
+		case smoothing <= 0:
+			d = minF(d, di)
+		default:
+			// Polynomial smooth minimum.
+			h := clampF(0.5+0.5*(di-d)/smoothing, 0, 1)
+			d = di + (d-di)*h - smoothing*h*(1-h)
+		}
+	}
+	return d
+}
+
+// traceZeroLevel returns the closed outlines where the field of gx*gy samples is zero, by marching squares.
+// The points are in grid units, and the insides (negative values) are on the right of the outlines on screen.
+func traceZeroLevel(field []float32, gx, gy int) [][]float32 {
+	// Crossings are keyed by the grid edge: (j*gx+i)*2 for (i,j)-(i+1,j) and +1 for (i,j)-(i,j+1).
+	points := make(map[int][2]float32)
+	next := make(map[int]int)
+	crossing := func(i0, j0, i1, j1 int) (int, bool) {
+		v0, v1 := field[j0*gx+i0], field[j1*gx+i1]
+		if (v0 < 0) == (v1 < 0) {
+			return 0, false
+		}
+		key := (j0*gx + i0) * 2
+		if j1 != j0 {
+			key++
+		}
+		if _, ok := points[key]; !ok {
+			t := v0 / (v0 - v1)
+			points[key] = [2]float32{float32(i0) + float32(i1-i0)*t, float32(j0) + float32(j1-j0)*t}
+		}
+		return key, true
+	}
+	for j := 0; j+1 < gy; j++ {
+		for i := 0; i+1 < gx; i++ {
+			// Cell edges clockwise on screen from the top-left corner: top, right, bottom and left.
+			corners := [5][2]int{{i, j}, {i + 1, j}, {i + 1, j + 1}, {i, j + 1}, {i, j}}
+			var entries [2]int
+			ne := 0
+			var order [4]int // 1 for entries and 2 for exits, in edge order.
+			var keys [4]int
+			for e := 0; e < 4; e++ {
+				a, b := corners[e], corners[e+1]
+				if a[0] > b[0] || a[1] > b[1] {
+					a, b = b, a
+				}
+				key, ok := crossing(a[0], a[1], b[0], b[1])
+				if !ok {
+					continue
+				}
+				keys[e] = key
+				if field[corners[e][1]*gx+corners[e][0]] < 0 {
+					order[e] = 2
+				} else {
+					order[e] = 1
+				}
+			}
+			for e := 0; e < 4; e++ {
+				if order[e] == 1 {
+					entries[ne] = e
+					ne++
+				}
+			}
+			if ne == 0 {
+				continue
+			}
+			// Each entry is joined to the following exit, or to the preceding one when the insides of a saddle
+			// are connected through the center of the cell.
+			connected := false
+			if ne == 2 {
+				center := (field[j*gx+i] + field[j*gx+i+1] + field[(j+1)*gx+i] + field[(j+1)*gx+i+1]) * 0.25
+				connected = center < 0
+			}
+			for k := 0; k < ne; k++ {
+				e := entries[k]
+				step := 1
+				if connected {
+					step = 3
+				}
+				x := (e + step) % 4
+				for order[x] != 2 {
+					x = (x + step) % 4
+				}
+				next[keys[e]] = keys[x]
+			}
+		}
+	}
+
+	starts := make([]int, 0, len(next))
+	for key := range next {
+		starts = append(starts, key)
+	}
+	// Map order is random, start the outlines in the same order every time.
+	sort.Ints(starts)
+	var loops [][]float32
+	for _, start := range starts {
+		if _, ok := points[start]; !ok {
+			continue
+		}
+		var loop []float32
+		for key := start; ; {
+			p, ok := points[key]
+			if !ok {
+				break
+			}
+			loop = append(loop, p[0], p[1])
+			delete(points, key)
+			key = next[key]
+		}
+		if len(loop) >= 6 {
+			loops = append(loops, loop)
+		}
+	}
+	return loops
+}
+
+// loopArea returns the signed area of the outline of x, y pairs, positive for clockwise on screen.
+func loopArea(loop []float32) float32 {
+	var area float32
+	n := len(loop)
+	for k := 0; k < n; k += 2 {
+		x0, y0 := loop[k], loop[k+1]
+		x1, y1 := loop[(k+2)%n], loop[(k+3)%n]
+		area += x0*y1 - x1*y0
+	}
+	return area * 0.5
+}
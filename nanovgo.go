@@ -1,12 +1,17 @@
 package nanovgo
 
 import (
-	"bytes"
+	"errors"
+	"fmt"
 	"image"
+	"image/draw"
 	_ "image/jpeg" // to read jpeg
 	_ "image/png"  // to read png
+	"io"
 	"log"
 	"os"
+	"sort"
+	"unicode"
 
 	"nanovgo/fontstashmini"
 )
@@ -106,29 +111,67 @@ import (
 //	vg.RoundedRect(bounds[0],bounds[1], bounds[2]-bounds[0], bounds[3]-bounds[1])
 //	vg.Fill()
 //
-// Note: currently only solid color fill is supported for text.
+// Note: gradient and pattern fills of text need a backend which supports them (e.g. the GL backend),
+// other backends draw text with the inner color of the fill paint.
 type Context struct {
 	params         nvgParams
 	commands       []float32
 	commandX       float32
 	commandY       float32
+	lastPointIdx   int
+	moveToIdx      int
 	states         []nvgState
 	cache          nvgPathCache
 	tessTol        float32
 	distTol        float32
 	fringeWidth    float32
 	devicePxRatio  float32
+	dpi            float32
+	autoWinding    bool
+	premultiplied  bool
+	snapToPixel    bool
+	arcFlatten     ArcFlattenMode
+	missingGlyph   func(r rune, fontID int)
+	culling        bool
+	viewSize       [2]float32
+	checkOwner     bool
+	ownerID        uint64
+	atlasOverflow  func()
+	imageSets      map[int]map[float32]int
+	genImages      map[nvgGeneratedImage]int
+	genImagesUsed  map[nvgGeneratedImage]bool
+	images         map[int]struct{}
+	palettedImages map[int][]byte
+	imageErr       error
+	decoders       []nvgImageDecoder
+	layerCalls     []nvgLayerCall
+	layerZ         int
+	inLayer        bool
 	fs             *fontstashmini.FontStash
 	fontImages     []int
 	fontImageIdx   int
+	fontImageMax   int
+	frameTime      float32
+	frameDelta     float32
 	drawCallCount  int
+	flushedCalls   int
 	fillTriCount   int
 	strokeTriCount int
 	textTriCount   int
 }
 
 // Delete is called when tearing down NanoVGo context
+// It deletes font atlas images and all images created by CreateImage* functions which are not deleted yet.
 func (ctx *Context) Delete() {
+	for _, img := range ctx.LiveImages() {
+		ctx.params.renderDeleteTexture(img)
+	}
+	ctx.images = nil
+	ctx.imageSets = nil
+	for _, img := range ctx.genImages {
+		ctx.params.renderDeleteTexture(img)
+	}
+	ctx.genImages = nil
 
 	for i, fontImage := range ctx.fontImages {
 		if fontImage != 0 {
@@ -139,6 +182,33 @@ func (ctx *Context) Delete() {
 	ctx.params.renderDelete()
 }
 
+// Recycle clears the per-frame state of the context so that it can be reused, e.g. for another window,
+// instead of creating a new one. Pending draw calls are canceled, the state stack is reset to a single
+// default state and the current path is cleared, while the allocated buffers, loaded fonts, font atlas
+// and images are kept. Since textures belong to the backend, the new render target must share them
+// with the old one (e.g. the same or a shared GL context).
+func (ctx *Context) Recycle() {
+	ctx.clearLayerCalls()
+	ctx.params.renderCancel()
+	ctx.commands = ctx.commands[:0]
+	ctx.commandX, ctx.commandY = 0, 0
+	ctx.lastPointIdx = -1
+	ctx.moveToIdx = -1
+	ctx.cache.clearPathCache()
+	ctx.states = ctx.states[:0]
+	ctx.Save()
+	ctx.Reset()
+	ctx.setDevicePixelRatio(1.0)
+	ctx.ownerID = 0
+	ctx.frameTime = 0
+	ctx.frameDelta = 0
+	ctx.drawCallCount = 0
+	ctx.flushedCalls = 0
+	ctx.fillTriCount = 0
+	ctx.strokeTriCount = 0
+	ctx.textTriCount = 0
+}
+
 // BeginFrame begins drawing a new frame
 // Calls to NanoVGo drawing API should be wrapped in Context.BeginFrame() & Context.EndFrame()
 // Context.BeginFrame() defines the size of the window to render to in relation currently
@@ -147,28 +217,144 @@ func (ctx *Context) Delete() {
 // For example, GLFW returns two dimension for an opened window: window size and
 // frame buffer size. In that case you would set windowWidth/Height to the window size
 // devicePixelRatio to: frameBufferWidth / windowWidth.
+// Window dimensions smaller than 1 are clamped to 1 and devicePixelRatio is clamped to 1/64
+// (a zero, negative or NaN ratio too), so that the tessellation tolerances stay finite.
 func (ctx *Context) BeginFrame(windowWidth, windowHeight int, devicePixelRatio float32) {
+	if ctx.checkOwner {
+		ctx.ownerID = goroutineID()
+	}
 	ctx.states = ctx.states[:0]
 	ctx.Save()
 	ctx.Reset()
 
+	windowWidth, windowHeight = maxI(windowWidth, 1), maxI(windowHeight, 1)
+	if !(devicePixelRatio >= nvgMinPixelRatio) {
+		devicePixelRatio = nvgMinPixelRatio
+	}
 	ctx.setDevicePixelRatio(devicePixelRatio)
+	ctx.clearLayerCalls()
 	ctx.params.renderViewport(windowWidth, windowHeight)
+	ctx.viewSize = [2]float32{float32(windowWidth), float32(windowHeight)}
+	ctx.pruneGeneratedImages()
 
 	ctx.drawCallCount = 0
+	ctx.flushedCalls = 0
 	ctx.fillTriCount = 0
 	ctx.strokeTriCount = 0
 	ctx.textTriCount = 0
 }
 
+// SetFrameTime sets the time of the current frame in seconds. Call it once per frame, after BeginFrame().
+// Animated helpers, e.g. SetLineDashSpeed(), use it as their clock.
+func (ctx *Context) SetFrameTime(seconds float32) {
+	ctx.frameDelta = seconds - ctx.frameTime
+	ctx.frameTime = seconds
+}
+
+// FrameTime returns the time of the current frame set by SetFrameTime().
+func (ctx *Context) FrameTime() float32 {
+	return ctx.frameTime
+}
+
+// FrameDelta returns the difference between the times set by the last two SetFrameTime() calls.
+func (ctx *Context) FrameDelta() float32 {
+	return ctx.frameDelta
+}
+
 // CancelFrame cancels drawing the current frame.
 func (ctx *Context) CancelFrame() {
+	ctx.clearLayerCalls()
 	ctx.params.renderCancel()
+	ctx.flushedCalls = ctx.drawCallCount
+}
+
+// Layer calls build and draws what it draws at depth z over the content drawn outside of layers.
+// Layers are drawn at EndFrame() in increasing z, and in call order for equal z. Nested layers
+// use their own z. Only the drawing is deferred: the state changes made by build are kept.
+// The z only orders layers among themselves: the content drawn outside of layers is handed over to
+// the backend as it is drawn, so all layers are drawn over it, also with negative z.
+func (ctx *Context) Layer(z int, build func()) {
+	oldZ, oldInLayer := ctx.layerZ, ctx.inLayer
+	ctx.layerZ, ctx.inLayer = z, true
+	defer func() {
+		ctx.layerZ, ctx.inLayer = oldZ, oldInLayer
+	}()
+	build()
+}
+
+func (ctx *Context) recordLayerCall(draw func(params nvgParams)) {
+	ctx.layerCalls = append(ctx.layerCalls, nvgLayerCall{z: ctx.layerZ, draw: draw})
+}
+
+func (ctx *Context) flushLayerCalls() {
+	sort.SliceStable(ctx.layerCalls, func(i, j int) bool {
+		return ctx.layerCalls[i].z < ctx.layerCalls[j].z
+	})
+	for _, call := range ctx.layerCalls {
+		call.draw(ctx.params)
+	}
+	ctx.clearLayerCalls()
+}
+
+func (ctx *Context) clearLayerCalls() {
+	for i := range ctx.layerCalls {
+		ctx.layerCalls[i] = nvgLayerCall{}
+	}
+	ctx.layerCalls = ctx.layerCalls[:0]
+}
+
+// HasPendingDraws returns true if draw calls were issued since the beginning of the frame or since
+// the last Flush(), EndFrame() or CancelFrame(), and are not handed over to the backend yet.
+func (ctx *Context) HasPendingDraws() bool {
+	return ctx.drawCallCount > ctx.flushedCalls
+}
+
+// RenderStats returns the numbers of draw calls and triangles since BeginFrame() or ResetStats().
+func (ctx *Context) RenderStats() RenderStats {
+	return RenderStats{
+		DrawCalls:       ctx.drawCallCount,
+		FillTriangles:   ctx.fillTriCount,
+		StrokeTriangles: ctx.strokeTriCount,
+		TextTriangles:   ctx.textTriCount,
+	}
+}
+
+// ResetStats zeroes the counters of RenderStats() in the middle of the frame, e.g. to measure the cost of
+// a part of the UI. The path, the state and the pending draws are not affected.
+func (ctx *Context) ResetStats() {
+	// Pending draws are counted from the draw calls.
+	ctx.flushedCalls -= ctx.drawCallCount
+	ctx.drawCallCount = 0
+	ctx.fillTriCount = 0
+	ctx.strokeTriCount = 0
+	ctx.textTriCount = 0
+}
+
+// Capabilities returns the optional features supported by the backend of the context.
+func (ctx *Context) Capabilities() Capabilities {
+	return ctx.params.renderCapabilities()
+}
+
+// Flush hands the pending draw calls over to the backend, e.g. before drawing with GL directly, while the
+// frame stays open. Unlike EndFrame(), the state, the layers and the font atlas pages are kept: layers are
+// still drawn at EndFrame() and the atlas pages are compacted only at the end of the frame.
+func (ctx *Context) Flush() {
+	ctx.params.renderFlush()
+	ctx.flushedCalls = ctx.drawCallCount
 }
 
 // EndFrame ends drawing flushing remaining render state.
+// The flush is skipped when nothing was drawn since BeginFrame() or the last Flush(), so that redrawing only
+// on change is cheap. The font atlas pages are compacted in both cases.
 func (ctx *Context) EndFrame() {
-	ctx.params.renderFlush()
+	if _, ok := ctx.params.(nvgDocumentWriter); ok || ctx.HasPendingDraws() || len(ctx.layerCalls) > 0 {
+		ctx.flushLayerCalls()
+		ctx.params.renderFlush()
+	} else {
+		// Drop what the backend may have queued without drawing anything, e.g. fills of empty paths.
+		ctx.params.renderCancel()
+	}
+	ctx.flushedCalls = ctx.drawCallCount
 	if ctx.fontImageIdx != 0 {
 		fontImage := ctx.fontImages[ctx.fontImageIdx]
 		if fontImage == 0 {
@@ -200,6 +386,7 @@ func (ctx *Context) EndFrame() {
 // Save pushes and saves the current render state into a state stack.
 // A matching Restore() must be used to restore the state.
 func (ctx *Context) Save() {
+	ctx.checkConcurrency()
 	if len(ctx.states) >= nvgMaxStates {
 		return
 	}
@@ -212,12 +399,29 @@ func (ctx *Context) Save() {
 
 // Restore pops and restores current render state.
 func (ctx *Context) Restore() {
+	ctx.checkConcurrency()
 	nStates := len(ctx.states)
 	if nStates > 1 {
 		ctx.states = ctx.states[:nStates-1]
 	}
 }
 
+// SetConcurrencyCheck enables or disables goroutine ownership check (disabled by default).
+// When enabled, the context panics if it is used from a goroutine other than the one that called BeginFrame().
+// The check has runtime cost, so use it only while debugging.
+func (ctx *Context) SetConcurrencyCheck(enabled bool) {
+	ctx.checkOwner = enabled
+	ctx.ownerID = 0
+	if enabled {
+		ctx.ownerID = goroutineID()
+	}
+}
+
+// ConcurrencyCheck gets whether goroutine ownership check is enabled.
+func (ctx *Context) ConcurrencyCheck() bool {
+	return ctx.checkOwner
+}
+
 // Block makes Save/Restore block.
 func (ctx *Context) Block(block func()) {
 	ctx.Save()
@@ -225,11 +429,34 @@ func (ctx *Context) Block(block func()) {
 	block()
 }
 
+// ClipRect makes Save/Restore block like Block() which draws only inside the rectangle: the scissor is
+// intersected with it by IntersectScissor() before draw is called. An existing scissor is not replaced,
+// so nested clips only get smaller.
+func (ctx *Context) ClipRect(x, y, w, h float32, draw func()) {
+	ctx.Save()
+	defer ctx.Restore()
+	ctx.IntersectScissor(x, y, w, h)
+	draw()
+}
+
 // Reset resets current render state to default values. Does not affect the render state stack.
 func (ctx *Context) Reset() {
 	ctx.getState().reset()
 }
 
+// ResetStyles resets fill and stroke styles of current render state to default values:
+// fill and stroke paints, stroke width, miter limit, line cap, line join, stroke align,
+// stroke overlap mode and global, fill and stroke alphas. Transform, scissor and text styles are kept.
+func (ctx *Context) ResetStyles() {
+	ctx.getState().resetStyles()
+}
+
+// ResetText resets text styles of current render state to default values:
+// font face, font size, text align, letter spacing, font blur and line height.
+func (ctx *Context) ResetText() {
+	ctx.getState().resetText()
+}
+
 // SetStrokeWidth sets the stroke width of the stroke style.
 func (ctx *Context) SetStrokeWidth(width float32) {
 	ctx.getState().strokeWidth = width
@@ -273,6 +500,127 @@ func (ctx *Context) LineJoin() LineCap {
 	return ctx.getState().lineJoin
 }
 
+// DefaultStyle returns the style of a new frame: stroke width 1, butt line cap, miter line join,
+// miter limit 10, black stroke, white fill and global alpha 1.
+func DefaultStyle() Style {
+	return Style{
+		StrokeWidth: 1,
+		LineCap:     Butt,
+		LineJoin:    Miter,
+		MiterLimit:  10,
+		StrokeColor: RGBA(0, 0, 0, 255),
+		FillColor:   RGBA(255, 255, 255, 255),
+		GlobalAlpha: 1,
+	}
+}
+
+// ApplyStyle sets the stroke width, line cap, line join, miter limit, stroke and fill colors and global alpha
+// of the style, so ApplyStyle(CurrentStyle()) changes nothing. The other settings of the state are kept.
+func (ctx *Context) ApplyStyle(s Style) {
+	ctx.SetStrokeWidth(s.StrokeWidth)
+	ctx.SetLineCap(s.LineCap)
+	ctx.SetLineJoin(s.LineJoin)
+	ctx.SetMiterLimit(s.MiterLimit)
+	ctx.SetStrokeColor(s.StrokeColor)
+	ctx.SetFillColor(s.FillColor)
+	ctx.SetGlobalAlpha(s.GlobalAlpha)
+}
+
+// CurrentStyle returns the settings of the state which ApplyStyle() sets. Colors of gradient and pattern
+// paints are their inner colors.
+func (ctx *Context) CurrentStyle() Style {
+	state := ctx.getState()
+	return Style{
+		StrokeWidth: state.strokeWidth,
+		LineCap:     state.lineCap,
+		LineJoin:    state.lineJoin,
+		MiterLimit:  state.miterLimit,
+		StrokeColor: state.stroke.innerColor,
+		FillColor:   state.fill.innerColor,
+		GlobalAlpha: state.alpha,
+	}
+}
+
+// SetLineDash sets the dash pattern of strokes as lengths of alternating dashes and gaps.
+// A pattern with odd number of lengths is repeated to make it even, like in HTML5 canvas.
+// Dashes are measured along the path including curves, and the pattern starts again at each sub-path.
+// Empty pattern or a pattern with negative lengths or zero total length draws solid lines.
+// Sub-paths which would have more than about 65000 dashes are drawn solid too.
+// Stroke align is not applied to dashed strokes.
+func (ctx *Context) SetLineDash(dashes []float32) {
+	state := ctx.getState()
+	var total float32
+	for _, dash := range dashes {
+		if dash < 0 {
+			state.lineDash = nil
+			return
+		}
+		total += dash
+	}
+	if total == 0 {
+		state.lineDash = nil
+		return
+	}
+	state.lineDash = append([]float32(nil), dashes...)
+	if len(dashes)%2 == 1 {
+		state.lineDash = append(state.lineDash, dashes...)
+	}
+}
+
+// LineDash returns the dash pattern of strokes, or nil for solid lines.
+func (ctx *Context) LineDash() []float32 {
+	return append([]float32(nil), ctx.getState().lineDash...)
+}
+
+// SetLineDashOffset sets the distance along the path where the dash pattern starts.
+func (ctx *Context) SetLineDashOffset(offset float32) {
+	ctx.getState().dashOffset = offset
+}
+
+// LineDashOffset returns the distance along the path where the dash pattern starts.
+func (ctx *Context) LineDashOffset() float32 {
+	return ctx.getState().dashOffset
+}
+
+// SetLineDashSpeed moves the dash pattern along the path by speed units per second of FrameTime(),
+// e.g. for marching ants around a selection. The offset set by SetLineDashOffset() is added to it.
+// Zero speed (default) keeps the dashes still.
+func (ctx *Context) SetLineDashSpeed(speed float32) {
+	ctx.getState().dashSpeed = speed
+}
+
+// LineDashSpeed returns the speed of the dash pattern along the path in units per second.
+func (ctx *Context) LineDashSpeed() float32 {
+	return ctx.getState().dashSpeed
+}
+
+// SetStrokeAlign sets where the stroke is drawn relative to the path.
+// Can be one of StrokeCenter (default), StrokeInner, StrokeOuter.
+// Inner and outer sides are decided by the area enclosed by each sub-path,
+// and open sub-paths are offset as a whole.
+func (ctx *Context) SetStrokeAlign(align StrokeAlign) {
+	ctx.getState().strokeAlign = align
+}
+
+// StrokeAlign gets where the stroke is drawn relative to the path.
+func (ctx *Context) StrokeAlign() StrokeAlign {
+	return ctx.getState().strokeAlign
+}
+
+// SetStrokeOverlapMode sets how self-overlapping parts of strokes are drawn.
+// Can be one of OverlapNaive (default), OverlapUnified.
+// OverlapUnified avoids darker seams of translucent strokes at joins and intersections. On GL backend, it
+// needs stencil buffer and draws each stroke three times (coverage, color and clear passes), so it is slower.
+// It has no effect on the backends which don't support it.
+func (ctx *Context) SetStrokeOverlapMode(mode OverlapMode) {
+	ctx.getState().strokeOverlap = mode
+}
+
+// StrokeOverlapMode gets how self-overlapping parts of strokes are drawn.
+func (ctx *Context) StrokeOverlapMode() OverlapMode {
+	return ctx.getState().strokeOverlap
+}
+
 // SetGlobalAlpha sets the transparency applied to all rendered shapes.
 // Already transparent paths will get proportionally more transparent as well.
 func (ctx *Context) SetGlobalAlpha(alpha float32) {
@@ -284,6 +632,34 @@ func (ctx *Context) GlobalAlpha() float32 {
 	return ctx.getState().alpha
 }
 
+// MultiplyGlobalAlpha multiplies the current global alpha by alpha.
+// Unlike SetGlobalAlpha(), nested calls compose, e.g. two calls with 0.5 result in 0.25.
+func (ctx *Context) MultiplyGlobalAlpha(alpha float32) {
+	ctx.getState().alpha *= alpha
+}
+
+// SetFillAlpha sets the transparency applied to filled shapes only.
+// It is combined with the global alpha, so both have to be 1.0 for opaque fills.
+func (ctx *Context) SetFillAlpha(alpha float32) {
+	ctx.getState().fillAlpha = alpha
+}
+
+// FillAlpha gets the transparency applied to filled shapes.
+func (ctx *Context) FillAlpha() float32 {
+	return ctx.getState().fillAlpha
+}
+
+// SetStrokeAlpha sets the transparency applied to stroked shapes only.
+// It is combined with the global alpha, so both have to be 1.0 for opaque strokes.
+func (ctx *Context) SetStrokeAlpha(alpha float32) {
+	ctx.getState().strokeAlpha = alpha
+}
+
+// StrokeAlpha gets the transparency applied to stroked shapes.
+func (ctx *Context) StrokeAlpha() float32 {
+	return ctx.getState().strokeAlpha
+}
+
 // SetTransform premultiplies current coordinate system by specified matrix.
 func (ctx *Context) SetTransform(t TransformMatrix) {
 	state := ctx.getState()
@@ -351,7 +727,9 @@ func (ctx *Context) CurrentTransform() TransformMatrix {
 
 // SetStrokeColor sets current stroke style to a solid color.
 func (ctx *Context) SetStrokeColor(color Color) {
-	ctx.getState().stroke.setPaintColor(color)
+	state := ctx.getState()
+	state.stroke.setPaintColor(color)
+	state.strokeAlong = false
 }
 
 // SetStrokePaint sets current stroke style to a paint, which can be a one of the gradients or a pattern.
@@ -359,6 +737,18 @@ func (ctx *Context) SetStrokePaint(paint Paint) {
 	state := ctx.getState()
 	state.stroke = paint
 	state.stroke.xform = state.stroke.xform.Multiply(state.xform)
+	state.strokeAlong = false
+}
+
+// SetStrokePaintAlongPath sets current stroke style to a gradient which runs along the stroke,
+// from startColor at the start of the path to endColor at its end. Sub-paths are treated as concatenated.
+// The stroke is drawn as textured triangles without edge anti-aliasing.
+// SetStrokeColor() and SetStrokePaint() replace it.
+func (ctx *Context) SetStrokePaintAlongPath(startColor, endColor Color) {
+	state := ctx.getState()
+	state.stroke.setPaintColor(RGBAf(1, 1, 1, 1))
+	state.strokeAlong = true
+	state.strokeColors = [2]Color{startColor, endColor}
 }
 
 // SetFillColor sets current fill style to a solid color.
@@ -373,54 +763,277 @@ func (ctx *Context) SetFillPaint(paint Paint) {
 	state.fill.xform = state.fill.xform.Multiply(state.xform)
 }
 
+// ImagePatternRegion creates and returns an image pattern which maps the region of the image (in image pixels)
+// to the rectangle (x,y)-(x+w,y+h) rotated by angle around (x,y). Other parameters are same as ImagePattern().
+// Parts of the image outside the region are visible if the filled shape is larger than the rectangle.
+func (ctx *Context) ImagePatternRegion(x, y, w, h, angle float32, img int, region image.Rectangle, alpha float32) Paint {
+	iw, ih, _ := ctx.ImageSize(img)
+	rw := region.Dx()
+	rh := region.Dy()
+	if rw <= 0 || rh <= 0 {
+		return ImagePattern(x, y, w, h, angle, img, alpha)
+	}
+	sx := w / float32(rw)
+	sy := h / float32(rh)
+	paint := ImagePattern(0, 0, float32(iw)*sx, float32(ih)*sy, 0, img, alpha)
+	paint.xform = TranslateMatrix(-float32(region.Min.X)*sx, -float32(region.Min.Y)*sy).Multiply(RotateMatrix(angle)).Multiply(TranslateMatrix(x, y))
+	return paint
+}
+
 // CreateImage creates image by loading it from the disk from specified file name.
+// JPEG images are rotated or flipped upright according to their EXIF orientation.
 // Returns handle to the image.
 func (ctx *Context) CreateImage(filePath string, flags ImageFlags) int {
 	file, err := os.Open(filePath)
 	defer file.Close()
 	if err != nil {
+		ctx.imageErr = err
 		return 0
 	}
-	img, _, err := image.Decode(file)
+	data, err := io.ReadAll(file)
 	if err != nil {
+		ctx.imageErr = err
 		return 0
 	}
-	return ctx.CreateImageFromGoImage(flags, img)
+	return ctx.CreateImageFromMemory(flags, data)
+}
+
+// RegisterImageDecoder registers decode for the format (e.g. "webp"), which is tried before the decoders
+// registered in the image package when images are created from files, memory or readers. Registered decoders
+// are tried in the order of registration, until one doesn't return an error. Registering a format again
+// replaces its decoder and nil decode removes it. No EXIF orientation is applied to their images.
+func (ctx *Context) RegisterImageDecoder(format string, decode func([]byte) (image.Image, error)) {
+	for i, decoder := range ctx.decoders {
+		if decoder.format == format {
+			ctx.decoders = append(ctx.decoders[:i], ctx.decoders[i+1:]...)
+			break
+		}
+	}
+	if decode != nil {
+		ctx.decoders = append(ctx.decoders, nvgImageDecoder{format: format, decode: decode})
+	}
+}
+
+// decodeImage decodes the image data with the registered decoders or like image.Decode().
+func (ctx *Context) decodeImage(data []byte) (image.Image, error) {
+	for _, decoder := range ctx.decoders {
+		if img, err := decoder.decode(data); err == nil && img != nil {
+			return img, nil
+		}
+	}
+	return decodeImage(data)
+}
+
+// LastImageError returns why the last CreateImage(), CreateImageFromMemory(), CreateImageFromReader() or
+// CreateImageFromGoImage() call failed, or nil if it succeeded.
+func (ctx *Context) LastImageError() error {
+	return ctx.imageErr
+}
+
+// CreateImageSet creates image set by loading image variants from files. paths is keyed by the scale of
+// each variant (e.g. 1 for normal and 2 for Hi-DPI one).
+// Returns handle to the image set, which can be used with ImagePattern() like other images. When the pattern
+// is drawn, the variant whose scale is nearest to the device pixel ratio is used. Variants which fail to load
+// are skipped, and 0 is returned when no variant can be loaded.
+// DeleteImage() with the handle deletes all of the variants.
+func (ctx *Context) CreateImageSet(paths map[float32]string, flags ImageFlags) int {
+	variants := make(map[float32]int)
+	handle := 0
+	var handleScale float32
+	for scale, path := range paths {
+		img := ctx.CreateImage(path, flags)
+		if img == 0 {
+			continue
+		}
+		variants[scale] = img
+		if handle == 0 || scale < handleScale {
+			handle = img
+			handleScale = scale
+		}
+	}
+	if handle == 0 {
+		return 0
+	}
+	if ctx.imageSets == nil {
+		ctx.imageSets = make(map[int]map[float32]int)
+	}
+	ctx.imageSets[handle] = variants
+	return handle
 }
 
 // CreateImageFromMemory creates image by loading it from the specified chunk of memory.
+// JPEG images are oriented like CreateImage() does.
 // Returns handle to the image.
 func (ctx *Context) CreateImageFromMemory(flags ImageFlags, data []byte) int {
-	reader := bytes.NewReader(data)
-	img, _, err := image.Decode(reader)
+	img, err := ctx.decodeImage(data)
 	if err != nil {
+		ctx.imageErr = err
 		return 0
 	}
 	return ctx.CreateImageFromGoImage(flags, img)
 }
 
+// CreateImageFromReader creates image by decoding it from the reader, e.g. a file of embed.FS.
+// JPEG images are oriented like CreateImage() does.
+// Returns handle to the image, or 0 and the error if reading or decoding fails.
+func (ctx *Context) CreateImageFromReader(flags ImageFlags, r io.Reader) (int, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		ctx.imageErr = err
+		return 0, err
+	}
+	img, err := ctx.decodeImage(data)
+	if err != nil {
+		ctx.imageErr = err
+		return 0, err
+	}
+	handle := ctx.CreateImageFromGoImage(flags, img)
+	if handle == 0 {
+		return 0, ctx.imageErr
+	}
+	return handle, nil
+}
+
 // CreateImageFromGoImage creates image by loading it from the specified image.Image object.
+// The image is converted to non-premultiplied RGBA which the backends expect (e.g. image.RGBA is premultiplied),
+// or to premultiplied RGBA if imageFlag has ImagePreMultiplied.
 // Returns handle to the image.
 func (ctx *Context) CreateImageFromGoImage(imageFlag ImageFlags, img image.Image) int {
 	bounds := img.Bounds()
 	size := bounds.Size()
-	rgba, ok := img.(*image.RGBA)
-	if ok {
-		return ctx.CreateImageRGBA(size.X, size.Y, imageFlag, rgba.Pix)
-	}
-	rgba = image.NewRGBA(bounds)
-	for x := 0; x < size.X; x++ {
-		for y := 0; y < size.Y; y++ {
-			rgba.Set(x, y, img.At(x, y))
+	var pix []byte
+	if imageFlag&ImagePreMultiplied != 0 {
+		rgba, ok := img.(*image.RGBA)
+		if !ok || rgba.Stride != size.X*4 {
+			rgba = image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+			draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+		}
+		pix = rgba.Pix
+	} else {
+		nrgba, ok := img.(*image.NRGBA)
+		if !ok || nrgba.Stride != size.X*4 {
+			nrgba = image.NewNRGBA(image.Rect(0, 0, size.X, size.Y))
+			draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
 		}
+		pix = nrgba.Pix
+	}
+	handle := ctx.CreateImageRGBA(size.X, size.Y, imageFlag, pix[:size.X*size.Y*4])
+	if handle == 0 {
+		ctx.imageErr = errors.New("can't create image")
+	} else {
+		ctx.imageErr = nil
 	}
-	return ctx.CreateImageRGBA(size.X, size.Y, imageFlag, rgba.Pix)
+	return handle
 }
 
 // CreateImageRGBA creates image from specified image data.
 // Returns handle to the image.
 func (ctx *Context) CreateImageRGBA(w, h int, imageFlags ImageFlags, data []byte) int {
-	return ctx.params.renderCreateTexture(nvgTextureRGBA, w, h, imageFlags, data)
+	return ctx.registerImage(ctx.params.renderCreateTexture(nvgTextureRGBA, w, h, imageFlags, data))
+}
+
+// CreateImageAlpha creates single channel (8-bit alpha) image from specified image data.
+// Data has w*h bytes. When it is used with ImagePattern(), the paint color tints it,
+// so it is useful for masks.
+// Returns handle to the image.
+func (ctx *Context) CreateImageAlpha(w, h int, imageFlags ImageFlags, data []byte) int {
+	return ctx.registerImage(ctx.params.renderCreateTexture(nvgTextureALPHA, w, h, imageFlags, data))
+}
+
+// CreatePalettedImage creates image from 8-bit color indices and a palette of up to 256 colors.
+// Data has w*h bytes. The palette can be changed later by SetPalette() without uploading indices again.
+// Paletted images are sampled without filtering.
+// Returns handle to the image.
+func (ctx *Context) CreatePalettedImage(w, h int, indices []byte, palette []Color, imageFlags ImageFlags) int {
+	if len(indices) < w*h {
+		return 0
+	}
+	if renderer, ok := ctx.params.(nvgPaletteRenderer); ok {
+		img := ctx.params.renderCreateTexture(nvgTextureALPHA, w, h, imageFlags, indices)
+		if img == 0 {
+			return 0
+		}
+		if err := renderer.renderSetTexturePalette(img, palette); err != nil {
+			ctx.params.renderDeleteTexture(img)
+			return 0
+		}
+		// The backend keeps the indices, only remember that the image is paletted.
+		ctx.addPalettedImage(img, nil)
+		return ctx.registerImage(img)
+	}
+	// The backend can't look up colors, so keep indices to expand them for the new palette.
+	img := ctx.params.renderCreateTexture(nvgTextureRGBA, w, h, imageFlags, expandPalette(indices[:w*h], palette))
+	if img != 0 {
+		ctx.addPalettedImage(img, append([]byte(nil), indices[:w*h]...))
+	}
+	return ctx.registerImage(img)
+}
+
+// addPalettedImage remembers the image as paletted with its indices, or nil if the backend keeps them.
+func (ctx *Context) addPalettedImage(img int, indices []byte) {
+	if ctx.palettedImages == nil {
+		ctx.palettedImages = make(map[int][]byte)
+	}
+	ctx.palettedImages[img] = indices
+}
+
+// SetPalette replaces the palette of the image created by CreatePalettedImage().
+// It returns an error for other images.
+func (ctx *Context) SetPalette(img int, palette []Color) error {
+	indices, ok := ctx.palettedImages[img]
+	if !ok {
+		return errors.New("image is not paletted")
+	}
+	if renderer, ok := ctx.params.(nvgPaletteRenderer); ok {
+		return renderer.renderSetTexturePalette(img, palette)
+	}
+	return ctx.UpdateImage(img, expandPalette(indices, palette))
+}
+
+// expandPalette converts color indices to RGBA data. Indices out of the palette are transparent.
+func expandPalette(indices []byte, palette []Color) []byte {
+	table := paletteData(palette)
+	data := make([]byte, len(indices)*4)
+	for i, index := range indices {
+		copy(data[i*4:i*4+4], table[int(index)*4:])
+	}
+	return data
+}
+
+// paletteData returns RGBA data of 256 palette entries.
+func paletteData(palette []Color) []byte {
+	data := make([]byte, 256*4)
+	for i, color := range palette {
+		if i == 256 {
+			break
+		}
+		data[i*4] = uint8(clampF(color.R, 0, 1)*255 + 0.5)
+		data[i*4+1] = uint8(clampF(color.G, 0, 1)*255 + 0.5)
+		data[i*4+2] = uint8(clampF(color.B, 0, 1)*255 + 0.5)
+		data[i*4+3] = uint8(clampF(color.A, 0, 1)*255 + 0.5)
+	}
+	return data
+}
+
+func (ctx *Context) registerImage(img int) int {
+	if img != 0 {
+		if ctx.images == nil {
+			ctx.images = make(map[int]struct{})
+		}
+		ctx.images[img] = struct{}{}
+	}
+	return img
+}
+
+// LiveImages returns handles of images created by CreateImage* functions which are not deleted yet,
+// in ascending order. Font atlas images are not included.
+func (ctx *Context) LiveImages() []int {
+	images := make([]int, 0, len(ctx.images))
+	for img := range ctx.images {
+		images = append(images, img)
+	}
+	sort.Ints(images)
+	return images
 }
 
 // UpdateImage updates image data specified by image handle.
@@ -439,9 +1052,38 @@ func (ctx *Context) ImageSize(img int) (int, int, error) {
 
 // DeleteImage deletes created image.
 func (ctx *Context) DeleteImage(img int) {
+	if variants, ok := ctx.imageSets[img]; ok {
+		delete(ctx.imageSets, img)
+		for _, variant := range variants {
+			delete(ctx.images, variant)
+			ctx.params.renderDeleteTexture(variant)
+		}
+		return
+	}
+	delete(ctx.images, img)
+	delete(ctx.palettedImages, img)
 	ctx.params.renderDeleteTexture(img)
 }
 
+// selectImageVariant replaces image set handle in the paint by the variant nearest to the device pixel ratio.
+// The pattern extent stays same, so the displayed size doesn't change.
+func (ctx *Context) selectImageVariant(paint *Paint) {
+	variants, ok := ctx.imageSets[paint.image]
+	if !ok {
+		return
+	}
+	bestDiff := float32(-1)
+	var bestScale float32
+	for scale, img := range variants {
+		diff := absF(scale - ctx.devicePxRatio)
+		if bestDiff < 0 || diff < bestDiff || (diff == bestDiff && scale > bestScale) {
+			paint.image = img
+			bestDiff = diff
+			bestScale = scale
+		}
+	}
+}
+
 // Scissor sets the current scissor rectangle.
 // The scissor rectangle is transformed by the current transform.
 func (ctx *Context) Scissor(x, y, w, h float32) {
@@ -454,6 +1096,18 @@ func (ctx *Context) Scissor(x, y, w, h float32) {
 	state.scissor.extent = [2]float32{w * 0.5, h * 0.5}
 }
 
+// SetScissorFeather sets the width of the soft edge of the scissor rectangle in pixels.
+// The scissor mask fades out smoothly over feather pixels centered on the scissor boundary.
+// Feather of 0 (default) uses the hard, one pixel wide edge.
+func (ctx *Context) SetScissorFeather(feather float32) {
+	ctx.getState().scissor.feather = maxF(feather, 0.0)
+}
+
+// ScissorFeather returns the width of the soft edge of the scissor rectangle.
+func (ctx *Context) ScissorFeather() float32 {
+	return ctx.getState().scissor.feather
+}
+
 // IntersectScissor calculates intersects current scissor rectangle with the specified rectangle.
 // The scissor rectangle is transformed by the current transform.
 // Note: in case the rotation of previous scissor rect differs from
@@ -468,16 +1122,35 @@ func (ctx *Context) IntersectScissor(x, y, w, h float32) {
 		return
 	}
 
-	pXform := state.scissor.xform.Multiply(state.xform.Inverse())
-	ex := state.scissor.extent[0]
-	ey := state.scissor.extent[1]
-
-	teX := ex * absF(pXform[0]) * ey * absF(pXform[2])
-	teY := ex * absF(pXform[1]) * ey * absF(pXform[3])
-	rect := intersectRects(pXform[4]-teX, pXform[5]-teY, teX*2, teY*2, x, y, w, h)
+	sx, sy, sw, sh, _ := ctx.CurrentScissor()
+	rect := IntersectRects(sx, sy, sw, sh, x, y, w, h)
 	ctx.Scissor(rect[0], rect[1], rect[2], rect[3])
 }
 
+// CurrentScissor returns the scissor rectangle in the current local coordinate space.
+// If the scissor is rotated relative to the current transform, its bounding box is returned;
+// use CurrentScissorTransform() to get the exact region. enabled is false when scissoring is disabled.
+func (ctx *Context) CurrentScissor() (x, y, w, h float32, enabled bool) {
+	xform, ex, ey, enabled := ctx.CurrentScissorTransform()
+	if !enabled {
+		return 0, 0, 0, 0, false
+	}
+	teX := ex*absF(xform[0]) + ey*absF(xform[2])
+	teY := ex*absF(xform[1]) + ey*absF(xform[3])
+	return xform[4] - teX, xform[5] - teY, teX * 2, teY * 2, true
+}
+
+// CurrentScissorTransform returns the scissor region as the rectangle (-extentX,-extentY)-(extentX,extentY)
+// transformed by xform into the current local coordinate space. enabled is false when scissoring is disabled.
+func (ctx *Context) CurrentScissorTransform() (xform TransformMatrix, extentX, extentY float32, enabled bool) {
+	state := ctx.getState()
+	if state.scissor.extent[0] < 0 {
+		return IdentityMatrix(), 0, 0, false
+	}
+	xform = state.scissor.xform.Multiply(state.xform.Inverse())
+	return xform, state.scissor.extent[0], state.scissor.extent[1], true
+}
+
 // ResetScissor resets and disables scissoring.
 func (ctx *Context) ResetScissor() {
 	state := ctx.getState()
@@ -488,7 +1161,10 @@ func (ctx *Context) ResetScissor() {
 
 // BeginPath clears the current path and sub-paths.
 func (ctx *Context) BeginPath() {
+	ctx.checkConcurrency()
 	ctx.commands = ctx.commands[:0]
+	ctx.lastPointIdx = -1
+	ctx.moveToIdx = -1
 	ctx.cache.clearPathCache()
 }
 
@@ -508,19 +1184,16 @@ func (ctx *Context) BezierTo(c1x, c1y, c2x, c2y, x, y float32) {
 }
 
 // QuadTo adds quadratic bezier segment from last point in the path via a control point to the specified point.
+// The segment is kept as quadratic in the path commands.
 func (ctx *Context) QuadTo(cx, cy, x, y float32) {
-	x0 := ctx.commandX
-	y0 := ctx.commandY
-	ctx.appendCommand([]float32{float32(nvgBEZIERTO),
-		x0 + 2.0/3.0*(cx-x0), y0 + 2.0/3.0*(cy-y0),
-		x + 2.0/3.0*(cx-x), y + 2.0/3.0*(cy-y),
-		x, y,
-	})
+	ctx.appendCommand([]float32{float32(nvgQUADTO), cx, cy, x, y})
 }
 
 // Arc creates new circle arc shaped sub-path. The arc center is at cx,cy, the arc radius is r,
 // and the arc is drawn from angle a0 to a1, and swept in direction dir (CounterClockwise, or Clockwise).
 // Angles are specified in radians.
+// The arc is made of cubic bezier segments of at most 90 degrees. Large radius arcs use more
+// segments (up to 64) so that the approximation error stays below the tessellation tolerance.
 func (ctx *Context) Arc(cx, cy, r, a0, a1 float32, dir Direction) {
 	var move nvgCommands
 	if len(ctx.commands) > 0 {
@@ -529,10 +1202,12 @@ func (ctx *Context) Arc(cx, cy, r, a0, a1 float32, dir Direction) {
 		move = nvgMOVETO
 	}
 
-	// Clamp angles
+	// Clamp angles. Sweeps within rounding error of the full turn are full circles.
 	da := a1 - a0
+	fullTurn := PI*2 - 1e-6*maxFs(1, absF(a0), absF(a1))
+	a0 = NormalizeAngle(a0)
 	if dir == Clockwise {
-		if absF(da) >= PI*2 {
+		if absF(da) >= fullTurn {
 			da = PI * 2
 		} else {
 			for da < 0.0 {
@@ -540,7 +1215,7 @@ func (ctx *Context) Arc(cx, cy, r, a0, a1 float32, dir Direction) {
 			}
 		}
 	} else {
-		if absF(da) >= PI*2 {
+		if absF(da) >= fullTurn {
 			da = -PI * 2
 		} else {
 			for da > 0.0 {
@@ -548,8 +1223,23 @@ func (ctx *Context) Arc(cx, cy, r, a0, a1 float32, dir Direction) {
 			}
 		}
 	}
-	// Split arc into max 90 degree segments.
-	nDivs := clampI(int(absF(da)/(PI*0.5)+0.5), 1, 5)
+	if ctx.arcFlatten > 0 {
+		nDivs := maxI(1, int(ceilF(absF(da)/(PI*0.5)*float32(ctx.arcFlatten)-1e-3)))
+		values := make([]float32, 0, 3+nDivs*3)
+		for i := 0; i <= nDivs; i++ {
+			dy, dx := sinCosF(a0 + da*float32(i)/float32(nDivs))
+			if i == 0 {
+				values = append(values, float32(move), cx+dx*r, cy+dy*r)
+			} else {
+				values = append(values, float32(nvgLINETO), cx+dx*r, cy+dy*r)
+			}
+		}
+		ctx.appendCommand(values)
+		return
+	}
+
+	// Split arc into max 90 degree segments, and more for large radius to keep it round.
+	nDivs := arcDivs(r*ctx.getState().xform.getAverageScale(), da, ctx.tessTol)
 	hda := da / float32(nDivs) / 2.0
 	sin, cos := sinCosF(hda)
 	kappa := absF(4.0 / 3.0 * (1.0 - cos) / sin)
@@ -557,7 +1247,7 @@ func (ctx *Context) Arc(cx, cy, r, a0, a1 float32, dir Direction) {
 	if dir == CounterClockwise {
 		kappa = -kappa
 	}
-	values := make([]float32, 0, 3+5*7+100)
+	values := make([]float32, 0, 3+nDivs*7)
 	var px, py, pTanX, pTanY float32
 
 	for i := 0; i <= nDivs; i++ {
@@ -592,7 +1282,7 @@ func (ctx *Context) ArcTo(x1, y1, x2, y2, radius float32) {
 	if ptEquals(x0, y0, x1, y1, ctx.distTol) ||
 		ptEquals(x1, y1, x2, y2, ctx.distTol) ||
 		distPtSeg(x1, y1, x0, y0, x2, y2) < ctx.distTol*ctx.distTol ||
-		radius < ctx.distTol {
+		!(radius >= ctx.distTol) {
 		ctx.LineTo(x1, y1)
 		return
 	}
@@ -604,16 +1294,19 @@ func (ctx *Context) ArcTo(x1, y1, x2, y2, radius float32) {
 	dy1 := y2 - y1
 	_, dx0, dy0 = normalize(dx0, dy0)
 	_, dx1, dy1 = normalize(dx1, dy1)
+	// acosF clamps the dot product, rounding errors can put it slightly outside of [-1,1].
 	a := acosF(dx0*dx1 + dy0*dy1)
 	d := radius / tanF(a/2.0)
+	turn := cross(dx0, dy0, dx1, dy1)
 
-	if d > 10000.0 {
+	// Nearly collinear points put the circle too far (or at infinity) or make it vanish, NaN isn't less either.
+	if !(d <= 10000.0) || absF(turn) < 1e-6 {
 		ctx.LineTo(x1, y1)
 		return
 	}
 	var cx, cy, a0, a1 float32
 	var dir Direction
-	if cross(dx0, dy0, dx1, dy1) > 0.0 {
+	if turn > 0.0 {
 		cx = x1 + dx0*d + dy0*radius
 		cy = y1 + dy0*d + -dx0*radius
 		a0 = atan2F(dx0, -dy0)
@@ -640,6 +1333,32 @@ func (ctx *Context) Rect(x, y, w, h float32) {
 	})
 }
 
+// RectWith creates new rectangle shaped sub-path with the winding, e.g. Hole to cut it from the other sub-paths.
+func (ctx *Context) RectWith(x, y, w, h float32, winding Winding) {
+	ctx.Rect(x, y, w, h)
+	ctx.PathWinding(winding)
+}
+
+// UnionRects creates sub-paths of the outline of the union of the rectangles {x, y, w, h}, with Solid windings
+// around insides and Hole windings around holes, so that Fill() draws them as one shape without seams where
+// they overlap or touch, e.g. a highlight over several rows of text. Only axis-aligned rectangles are merged,
+// general boolean operations of paths are out of scope.
+// The package level UnionRects() returns the bounding rectangle of two rectangles instead.
+func (ctx *Context) UnionRects(rects [][4]float32) {
+	for _, loop := range rectUnionOutlines(rects) {
+		ctx.MoveTo(loop[0], loop[1])
+		for k := 2; k < len(loop); k += 2 {
+			ctx.LineTo(loop[k], loop[k+1])
+		}
+		ctx.ClosePath()
+		if loopArea(loop) > 0 {
+			ctx.PathWinding(Solid)
+		} else {
+			ctx.PathWinding(Hole)
+		}
+	}
+}
+
 // RoundedRect creates new rounded rectangle shaped sub-path.
 func (ctx *Context) RoundedRect(x, y, w, h, r float32) {
 	if r < 0.1 {
@@ -662,26 +1381,239 @@ func (ctx *Context) RoundedRect(x, y, w, h, r float32) {
 	}
 }
 
-// Ellipse creates new ellipse shaped sub-path.
-func (ctx *Context) Ellipse(cx, cy, rx, ry float32) {
-	ctx.appendCommand([]float32{
-		float32(nvgMOVETO), cx - rx, cy,
-		float32(nvgBEZIERTO), cx - rx, cy + ry*Kappa90, cx - rx*Kappa90, cy + ry, cx, cy + ry,
-		float32(nvgBEZIERTO), cx + rx*Kappa90, cy + ry, cx + rx, cy + ry*Kappa90, cx + rx, cy,
-		float32(nvgBEZIERTO), cx + rx, cy - ry*Kappa90, cx + rx*Kappa90, cy - ry, cx, cy - ry,
-		float32(nvgBEZIERTO), cx - rx*Kappa90, cy - ry, cx - rx, cy - ry*Kappa90, cx - rx, cy,
-		float32(nvgCLOSE),
-	})
+// BlurRegion blurs the already rendered pixels in the rectangle, like CSS backdrop-filter, with a gaussian
+// whose standard deviation is radius. The rectangle is transformed by the current transform and clipped by the
+// current scissor without anti-aliasing. Backends which can't read back rendered pixels (e.g. SVG) ignore it.
+func (ctx *Context) BlurRegion(x, y, w, h, radius float32) {
+	blurrer, ok := ctx.params.(nvgRegionBlurrer)
+	if !ok || w <= 0 || h <= 0 || radius <= 0 {
+		return
+	}
+	state := ctx.getState()
+	var quad [4]nvgVertex
+	quad[0].x, quad[0].y = state.xform.TransformPoint(x+w, y)
+	quad[1].x, quad[1].y = state.xform.TransformPoint(x, y)
+	quad[2].x, quad[2].y = state.xform.TransformPoint(x+w, y+h)
+	quad[3].x, quad[3].y = state.xform.TransformPoint(x, y+h)
+	radius *= state.xform.getAverageScale()
+	if ctx.inLayer {
+		scissor, vertexes, radius := state.scissor, append([]nvgVertex(nil), quad[:]...), radius
+		ctx.recordLayerCall(func(params nvgParams) {
+			params.(nvgRegionBlurrer).renderBlurRegion(&scissor, vertexes, radius)
+		})
+	} else {
+		blurrer.renderBlurRegion(&state.scissor, quad[:], radius)
+	}
+	ctx.drawCallCount++
 }
 
-// Circle creates new circle shaped sub-path.
-func (ctx *Context) Circle(cx, cy, r float32) {
-	ctx.Ellipse(cx, cy, r, r)
-}
+// FillVertexColors fills the quad with corners (quad[0],quad[1]), (quad[2],quad[3]), (quad[4],quad[5]) and
+// (quad[6],quad[7]) in order around it, interpolating colors[i] of each corner over the quad, like a simple mesh gradient.
+// The quad is transformed by the current transform and the current path isn't changed. Backends without
+// vertex colors (see Capabilities) fill the quad with the average of the colors.
+func (ctx *Context) FillVertexColors(quad [8]float32, colors [4]Color) {
+	state := ctx.getState()
+	for i := range colors {
+		colors[i].A *= state.alpha
+	}
+	var corners [4]nvgVertex
+	for i := range corners {
+		x, y := state.xform.TransformPoint(quad[i*2], quad[i*2+1])
+		corners[i].set(x, y, 0.5, 1.0)
+	}
+	// Front faces of the strip are counterclockwise on screen, the order of the corners may be either.
+	order := [4]int{0, 1, 3, 2}
+	if (corners[2].x-corners[0].x)*(corners[3].y-corners[1].y)-(corners[3].x-corners[1].x)*(corners[2].y-corners[0].y) > 0 {
+		order = [4]int{0, 3, 1, 2}
+	}
+	vertexes := make([]nvgVertex, 4)
+	stripColors := make([]Color, 4)
+	for i, j := range order {
+		vertexes[i] = corners[j]
+		stripColors[i] = colors[j]
+	}
 
-// ClosePath closes current sub-path with a line segment.
-func (ctx *Context) ClosePath() {
-	ctx.appendCommand([]float32{float32(nvgCLOSE)})
+	if renderer, ok := ctx.params.(nvgVertexColorRenderer); ok {
+		if ctx.inLayer {
+			scissor := state.scissor
+			ctx.recordLayerCall(func(params nvgParams) {
+				params.(nvgVertexColorRenderer).renderTriangleStripColors(&scissor, vertexes, stripColors)
+			})
+		} else {
+			renderer.renderTriangleStripColors(&state.scissor, vertexes, stripColors)
+		}
+	} else {
+		var average Color
+		for _, c := range colors {
+			average.R += c.R * 0.25
+			average.G += c.G * 0.25
+			average.B += c.B * 0.25
+			average.A += c.A * 0.25
+		}
+		var paint Paint
+		paint.setPaintColor(average)
+		ctx.renderTriangleStrip(&paint, &state.scissor, vertexes)
+	}
+	ctx.drawCallCount++
+	ctx.fillTriCount += 2
+}
+
+// DrawImage draws the image in the box with the top-left corner (x,y) and size (w,h), placed according to fit.
+// Scaled images are centered in the box and nothing is drawn outside of it. The current path is replaced.
+func (ctx *Context) DrawImage(img int, x, y, w, h float32, fit ImageFit) {
+	iw, ih, err := ctx.ImageSize(img)
+	if err != nil || iw <= 0 || ih <= 0 || w <= 0 || h <= 0 {
+		return
+	}
+	pw, ph := w, h
+	switch fit {
+	case FitContain:
+		scale := minF(w/float32(iw), h/float32(ih))
+		pw, ph = float32(iw)*scale, float32(ih)*scale
+	case FitCover:
+		scale := maxF(w/float32(iw), h/float32(ih))
+		pw, ph = float32(iw)*scale, float32(ih)*scale
+	case FitNone:
+		pw, ph = float32(iw), float32(ih)
+	}
+	px, py := x+(w-pw)*0.5, y+(h-ph)*0.5
+	// Only the part of the image inside the box is filled.
+	x0, y0 := maxF(x, px), maxF(y, py)
+	x1, y1 := minF(x+w, px+pw), minF(y+h, py+ph)
+	ctx.Block(func() {
+		ctx.BeginPath()
+		ctx.Rect(x0, y0, x1-x0, y1-y0)
+		ctx.SetFillPaint(ImagePattern(px, py, pw, ph, 0, img, 1))
+		ctx.Fill()
+	})
+}
+
+// DrawCheckerboard fills the rectangle with a checker pattern of cell sized squares of colors c0 and c1,
+// starting with c0 at the top-left corner. It is drawn as single rectangle with a repeated image pattern.
+// The current path is replaced.
+func (ctx *Context) DrawCheckerboard(x, y, w, h, cell float32, c0, c1 Color) {
+	if w <= 0 || h <= 0 || cell <= 0 {
+		return
+	}
+	img := ctx.generatedImage(nvgGeneratedImage{checker: true, colors: [2]Color{c0, c1}})
+	ctx.Block(func() {
+		ctx.BeginPath()
+		ctx.Rect(x, y, w, h)
+		ctx.SetFillPaint(ImagePattern(x, y, cell*2, cell*2, 0, img, 1))
+		ctx.Fill()
+	})
+}
+
+// DrawPoints draws round markers of radius in color at the points of xy, which has x and y of each point.
+// The centers are transformed by the current transform and the markers are scaled like the other shapes,
+// but they stay round. All of the markers are drawn at once as quads with an anti-aliased disk image,
+// so it is much faster than Circle() and Fill() for each point. The current path isn't changed.
+func (ctx *Context) DrawPoints(xy []float32, radius float32, color Color) {
+	n := len(xy) / 2
+	if n == 0 || radius <= 0 {
+		return
+	}
+	state := ctx.getState()
+	// The disk of the image has one pixel margin to the border of the quad.
+	r := radius * state.xform.getAverageScale() * nvgDotImageSize / (nvgDotImageSize - 2)
+
+	vertexes := ctx.cache.allocVertexes(n * 6)
+	for i := 0; i < n; i++ {
+		x, y := state.xform.TransformPoint(xy[i*2], xy[i*2+1])
+		x0, y0, x1, y1 := x-r, y-r, x+r, y+r
+		// Counterclockwise on screen.
+		v := vertexes[i*6 : i*6+6]
+		v[0].set(x0, y0, 0, 0)
+		v[1].set(x0, y1, 0, 1)
+		v[2].set(x1, y1, 1, 1)
+		v[3].set(x0, y0, 0, 0)
+		v[4].set(x1, y1, 1, 1)
+		v[5].set(x1, y0, 1, 0)
+	}
+
+	var paint Paint
+	color.A *= state.alpha
+	paint.setPaintColor(color)
+	paint.image = ctx.generatedImage(nvgGeneratedImage{dot: true})
+	ctx.renderTriangles(&paint, &state.scissor, vertexes)
+	ctx.drawCallCount++
+	ctx.fillTriCount += n * 2
+}
+
+// ChamferRect creates new rectangle shaped sub-path with corners cut by 45 degree segments.
+// cut is the length cut from each side at every corner, and it is clamped to half of the shorter side.
+func (ctx *Context) ChamferRect(x, y, w, h, cut float32) {
+	if cut <= 0 {
+		ctx.Rect(x, y, w, h)
+	} else {
+		cx := minF(cut, absF(w)*0.5) * signF(w)
+		cy := minF(cut, absF(h)*0.5) * signF(h)
+		ctx.appendCommand([]float32{
+			float32(nvgMOVETO), x, y + cy,
+			float32(nvgLINETO), x, y + h - cy,
+			float32(nvgLINETO), x + cx, y + h,
+			float32(nvgLINETO), x + w - cx, y + h,
+			float32(nvgLINETO), x + w, y + h - cy,
+			float32(nvgLINETO), x + w, y + cy,
+			float32(nvgLINETO), x + w - cx, y,
+			float32(nvgLINETO), x + cx, y,
+			float32(nvgCLOSE),
+		})
+	}
+}
+
+// RoundedRectWith creates new rounded rectangle shaped sub-path with the winding, see RectWith().
+func (ctx *Context) RoundedRectWith(x, y, w, h, r float32, winding Winding) {
+	ctx.RoundedRect(x, y, w, h, r)
+	ctx.PathWinding(winding)
+}
+
+// Ellipse creates new ellipse shaped sub-path.
+func (ctx *Context) Ellipse(cx, cy, rx, ry float32) {
+	if ctx.arcFlatten > 0 {
+		// Same start point and direction as the bezier segments.
+		n := int(ctx.arcFlatten) * 4
+		values := make([]float32, 0, n*3+1)
+		for i := 0; i < n; i++ {
+			dy, dx := sinCosF(PI - 2*PI*float32(i)/float32(n))
+			if i == 0 {
+				values = append(values, float32(nvgMOVETO), cx+dx*rx, cy+dy*ry)
+			} else {
+				values = append(values, float32(nvgLINETO), cx+dx*rx, cy+dy*ry)
+			}
+		}
+		ctx.appendCommand(append(values, float32(nvgCLOSE)))
+		return
+	}
+	ctx.appendCommand([]float32{
+		float32(nvgMOVETO), cx - rx, cy,
+		float32(nvgBEZIERTO), cx - rx, cy + ry*Kappa90, cx - rx*Kappa90, cy + ry, cx, cy + ry,
+		float32(nvgBEZIERTO), cx + rx*Kappa90, cy + ry, cx + rx, cy + ry*Kappa90, cx + rx, cy,
+		float32(nvgBEZIERTO), cx + rx, cy - ry*Kappa90, cx + rx*Kappa90, cy - ry, cx, cy - ry,
+		float32(nvgBEZIERTO), cx - rx*Kappa90, cy - ry, cx - rx, cy - ry*Kappa90, cx - rx, cy,
+		float32(nvgCLOSE),
+	})
+}
+
+// EllipseWith creates new ellipse shaped sub-path with the winding, see RectWith().
+func (ctx *Context) EllipseWith(cx, cy, rx, ry float32, winding Winding) {
+	ctx.Ellipse(cx, cy, rx, ry)
+	ctx.PathWinding(winding)
+}
+
+// Circle creates new circle shaped sub-path.
+func (ctx *Context) Circle(cx, cy, r float32) {
+	ctx.Ellipse(cx, cy, r, r)
+}
+
+// CircleWith creates new circle shaped sub-path with the winding, see RectWith().
+func (ctx *Context) CircleWith(cx, cy, r float32, winding Winding) {
+	ctx.EllipseWith(cx, cy, r, r, winding)
+}
+
+// ClosePath closes current sub-path with a line segment.
+func (ctx *Context) ClosePath() {
+	ctx.appendCommand([]float32{float32(nvgCLOSE)})
 }
 
 // PathWinding sets the current sub-path winding, see Winding.
@@ -689,6 +1621,323 @@ func (ctx *Context) PathWinding(winding Winding) {
 	ctx.appendCommand([]float32{float32(nvgWINDING), float32(winding)})
 }
 
+// SimplifyPath replaces the current path by its flattened sub-paths, with the points which are closer than
+// tolerance to the line between the points around them removed (Ramer-Douglas-Peucker algorithm).
+// Curves become line segments at the current tessellation tolerance. Closed sub-paths stay closed, and
+// explicit windings are kept.
+func (ctx *Context) SimplifyPath(tolerance float32) {
+	autoWinding := ctx.autoWinding
+	ctx.autoWinding = false
+	ctx.cache.clearPathCache()
+	ctx.flattenPaths()
+	ctx.autoWinding = autoWinding
+	// The commands are transformed, so is the tolerance.
+	tolerance *= ctx.getState().xform.getAverageScale()
+
+	var commands []float32
+	lastPointIdx, moveToIdx := -1, -1
+	for i := range ctx.cache.paths {
+		path := &ctx.cache.paths[i]
+		if path.count == 0 {
+			continue
+		}
+		points := ctx.cache.points[path.first : path.first+path.count]
+		if path.closed {
+			// The loop ends at its first point, so the point before it can be removed too.
+			points = append(append([]nvgPoint(nil), points...), points[0])
+		}
+		keep := simplifyPolyline(points, tolerance)
+		if path.closed {
+			points, keep = points[:len(points)-1], keep[:len(keep)-1]
+		}
+		command := nvgMOVETO
+		for j := range points {
+			if keep[j] {
+				lastPointIdx = len(commands) + 1
+				if command == nvgMOVETO {
+					moveToIdx = lastPointIdx
+				}
+				commands = append(commands, float32(command), points[j].x, points[j].y)
+				command = nvgLINETO
+			}
+		}
+		if path.closed {
+			commands = append(commands, float32(nvgCLOSE))
+		}
+		if path.explicitWinding {
+			commands = append(commands, float32(nvgWINDING), float32(path.winding))
+		}
+	}
+
+	ctx.commands = append(ctx.commands[:0], commands...)
+	ctx.lastPointIdx, ctx.moveToIdx = lastPointIdx, moveToIdx
+	if lastPointIdx >= 0 {
+		// commandX/Y are kept in user space.
+		ctx.commandX, ctx.commandY = ctx.getState().xform.Inverse().TransformPoint(commands[lastPointIdx], commands[lastPointIdx+1])
+	}
+	ctx.cache.clearPathCache()
+}
+
+// PathPointAt returns the position and the tangent angle (in radians) at normalized arc length t (0..1) of the current path.
+// The path is flattened with the current tessellation tolerance and its sub-paths are treated as concatenated.
+// Point order is kept as authored except for sub-paths with explicit PathWinding().
+// Returns zeros if the path is empty.
+func (ctx *Context) PathPointAt(t float32) (x, y, tangentAngle float32) {
+	autoWinding := ctx.autoWinding
+	ctx.autoWinding = false
+	ctx.cache.clearPathCache()
+	ctx.flattenPaths()
+	ctx.autoWinding = autoWinding
+
+	segments := func(path *nvgPath) int {
+		if path.closed {
+			return path.count
+		}
+		return path.count - 1
+	}
+
+	var total float32
+	for i := range ctx.cache.paths {
+		path := &ctx.cache.paths[i]
+		points := ctx.cache.points[path.first:]
+		for j := 0; j < segments(path); j++ {
+			total += points[j].len
+		}
+	}
+
+	var dx, dy float32
+	found := false
+	distance := clampF(t, 0, 1) * total
+	for i := 0; i < len(ctx.cache.paths) && !found; i++ {
+		path := &ctx.cache.paths[i]
+		points := ctx.cache.points[path.first:]
+		for j := 0; j < segments(path); j++ {
+			p0 := &points[j]
+			p1 := &points[(j+1)%path.count]
+			x, y, dx, dy = p1.x, p1.y, p0.dx, p0.dy
+			if distance <= p0.len {
+				u := distance / maxF(p0.len, 1e-6)
+				x, y = p0.x+(p1.x-p0.x)*u, p0.y+(p1.y-p0.y)*u
+				found = true
+				break
+			}
+			distance -= p0.len
+		}
+		if total == 0 && path.count > 0 {
+			x, y = points[0].x, points[0].y
+			found = true
+		}
+	}
+	ctx.cache.clearPathCache()
+
+	// Points are stored in device space, convert back to current user space.
+	inv := ctx.getState().xform.Inverse()
+	x, y = inv.TransformPoint(x, y)
+	tdx := dx*inv[0] + dy*inv[2]
+	tdy := dx*inv[1] + dy*inv[3]
+	return x, y, atan2F(tdy, tdx)
+}
+
+// PathBoundingCircle returns the smallest circle which encloses all points of the current path
+// in current local coordinate space. The path is flattened like Fill() does.
+// Returns zero radius circle at the origin if the path is empty.
+func (ctx *Context) PathBoundingCircle() (cx, cy, r float32) {
+	ctx.flattenPaths()
+	if len(ctx.cache.points) == 0 {
+		return 0, 0, 0
+	}
+	inv := ctx.getState().xform.Inverse()
+	points := make([][2]float32, len(ctx.cache.points))
+	for i, p := range ctx.cache.points {
+		points[i][0], points[i][1] = inv.TransformPoint(p.x, p.y)
+	}
+	return minEnclosingCircle(points)
+}
+
+// SetMaxTessellationDepth sets the maximum recursion depth of curve tessellation, which bounds
+// the number of segments of a bezier curve to 2^(n+1). The parts of a curve which aren't flat
+// at the depth are drawn as straight segments. The depth is clamped to 0-20, default is 10.
+func (ctx *Context) SetMaxTessellationDepth(n int) {
+	ctx.cache.maxDepth = clampI(n, 0, nvgMaxTessDepth)
+	ctx.cache.clearPathCache()
+}
+
+// MaxTessellationDepth returns the maximum recursion depth of curve tessellation.
+func (ctx *Context) MaxTessellationDepth() int {
+	return ctx.cache.maxDepth
+}
+
+// PathIsConvex returns whether the current path is a single convex sub-path, which the backend can fill
+// faster without stencil. The path is flattened like Fill() does, and open sub-paths are treated as closed.
+func (ctx *Context) PathIsConvex() bool {
+	ctx.flattenPaths()
+	if len(ctx.cache.paths) != 1 {
+		return false
+	}
+	path := &ctx.cache.paths[0]
+	if path.count < 3 {
+		return false
+	}
+	points := ctx.cache.points[path.first : path.first+path.count]
+	var sign, turn float32
+	for i := range points {
+		p0 := &points[(i+path.count-1)%path.count]
+		p1 := &points[i]
+		cross := p0.dx*p1.dy - p0.dy*p1.dx
+		if absF(cross) > 1e-6 {
+			if sign*cross < 0 {
+				return false
+			}
+			sign = cross
+		}
+		turn += atan2F(cross, p0.dx*p1.dx+p0.dy*p1.dy)
+	}
+	// Star shaped polygons turn consistently too, but go around more than once.
+	return absF(turn) < 2*PI+0.01
+}
+
+// SetSubPathWinding overrides the winding of the sub-path at specified index of the current path,
+// and corrects its point order like PathWinding() does. The path is flattened when it is called,
+// so it has to be called after the whole path is built. Out of range index is ignored.
+func (ctx *Context) SetSubPathWinding(index int, winding Winding) {
+	ctx.flattenPaths()
+	cache := &ctx.cache
+	if index < 0 || index >= len(cache.paths) {
+		return
+	}
+	path := &cache.paths[index]
+	path.winding = winding
+	path.explicitWinding = true
+	if path.count <= 2 {
+		return
+	}
+	points := cache.points[path.first : path.first+path.count]
+	area := polyArea(points, path.count)
+	if (winding == Solid && area < 0.0) || (winding == Hole && area > 0.0) {
+		polyReverse(points, path.count)
+		// Recalculate segment direction and length
+		for i := range points {
+			p0 := &points[i]
+			p1 := &points[(i+1)%path.count]
+			p0.len, p0.dx, p0.dy = normalize(p1.x-p0.x, p1.y-p0.y)
+		}
+	}
+}
+
+// SetAutoWinding sets whether sub-path point order is corrected by its area so that
+// it matches its winding (Solid or Hole). It is enabled by default.
+// When disabled, the authored point order is kept as is, and only sub-paths which
+// winding is specified explicitly by PathWinding() are corrected.
+func (ctx *Context) SetAutoWinding(enabled bool) {
+	ctx.autoWinding = enabled
+}
+
+// AutoWinding gets whether sub-path point order is corrected by its area.
+func (ctx *Context) AutoWinding() bool {
+	return ctx.autoWinding
+}
+
+// SetPremultipliedOutput sets whether the rendered colors are premultiplied by alpha, e.g. for a texture
+// which is composited with premultiplied blending. It is disabled by default (straight alpha), and both are
+// the same over an opaque framebuffer. It applies to the draws handed over to the backend after it, so set
+// it between frames. The GL backend honours it (see Capabilities.Premultiplied), the SVG and null
+// backends ignore it.
+func (ctx *Context) SetPremultipliedOutput(enabled bool) {
+	ctx.premultiplied = enabled
+	if outputter, ok := ctx.params.(nvgPremultipliedOutputter); ok {
+		outputter.setPremultipliedOutput(enabled)
+	}
+}
+
+// PremultipliedOutput gets whether the rendered colors are premultiplied by alpha.
+func (ctx *Context) PremultipliedOutput() bool {
+	return ctx.premultiplied
+}
+
+// SetArcFlatten sets whether Arc(), Circle() and Ellipse() are made of cubic bezier segments (ArcBezier,
+// the default) or of line segments (ArcLines(n)), e.g. for exports which only understand lines.
+// Line segments are not subdivided for large radius, so use enough of them to look as round.
+func (ctx *Context) SetArcFlatten(mode ArcFlattenMode) {
+	ctx.arcFlatten = mode
+}
+
+// ArcFlatten gets whether arcs are made of bezier or line segments.
+func (ctx *Context) ArcFlatten() ArcFlattenMode {
+	return ctx.arcFlatten
+}
+
+// SetSnapToPixel sets whether horizontal and vertical line segments (made by LineTo(), ClosePath() and Rect())
+// are snapped to the nearest device pixel center, to draw crisp thin lines.
+// Only segments which are axis aligned after the current transform are snapped, so diagonals are not distorted.
+func (ctx *Context) SetSnapToPixel(enabled bool) {
+	ctx.snapToPixel = enabled
+}
+
+// SnapToPixel gets whether horizontal and vertical line segments are snapped to device pixel centers.
+func (ctx *Context) SnapToPixel() bool {
+	return ctx.snapToPixel
+}
+
+// SetCulling sets whether Fill() and Stroke() skip paths which are entirely outside of the viewport
+// and the current scissor (disabled by default). Skipped paths are not tessellated nor counted in the stats.
+func (ctx *Context) SetCulling(enabled bool) {
+	ctx.culling = enabled
+}
+
+// Culling gets whether offscreen paths are skipped by Fill() and Stroke().
+func (ctx *Context) Culling() bool {
+	return ctx.culling
+}
+
+// isCulled returns true if culling is enabled and the flattened path bounds grown by margin
+// don't overlap the viewport and the scissor.
+func (ctx *Context) isCulled(margin float32) bool {
+	if !ctx.culling {
+		return false
+	}
+	bounds := ctx.cache.bounds
+	if bounds[0] > bounds[2] || bounds[1] > bounds[3] {
+		return false // no points
+	}
+	minX, minY := bounds[0]-margin, bounds[1]-margin
+	maxX, maxY := bounds[2]+margin, bounds[3]+margin
+	if maxX < 0 || maxY < 0 || minX > ctx.viewSize[0] || minY > ctx.viewSize[1] {
+		return true
+	}
+	scissor := &ctx.getState().scissor
+	if scissor.extent[0] < 0 {
+		return false
+	}
+	xform := &scissor.xform
+	teX := scissor.extent[0]*absF(xform[0]) + scissor.extent[1]*absF(xform[2]) + scissor.feather
+	teY := scissor.extent[0]*absF(xform[1]) + scissor.extent[1]*absF(xform[3]) + scissor.feather
+	return maxX < xform[4]-teX || maxY < xform[5]-teY || minX > xform[4]+teX || minY > xform[5]+teY
+}
+
+// SetTextPixelSnap sets whether glyph positions are snapped to whole device pixels (enabled by default).
+// Disable it for smoothly animated or scrolled text, at the cost of slightly blurry glyphs.
+func (ctx *Context) SetTextPixelSnap(enabled bool) {
+	ctx.fs.SetPixelSnap(enabled)
+}
+
+// TextPixelSnap gets whether glyph positions are snapped to whole device pixels on both axes.
+func (ctx *Context) TextPixelSnap() bool {
+	snapX, snapY := ctx.fs.PixelSnapAxes()
+	return snapX && snapY
+}
+
+// SetTextSnapAxes sets whether glyph positions are snapped to whole device pixels horizontally (with the
+// advances) and vertically, e.g. to keep crisp glyphs in a row of text which scrolls smoothly vertically
+// with SetTextSnapAxes(true, false). Both are enabled by default, SetTextPixelSnap() sets both.
+func (ctx *Context) SetTextSnapAxes(snapX, snapY bool) {
+	ctx.fs.SetPixelSnapAxes(snapX, snapY)
+}
+
+// TextSnapAxes gets whether glyph positions are snapped to whole device pixels horizontally and vertically.
+func (ctx *Context) TextSnapAxes() (snapX, snapY bool) {
+	return ctx.fs.PixelSnapAxes()
+}
+
 // DebugDumpPathCache prints cached path information to console
 func (ctx *Context) DebugDumpPathCache() {
 	log.Printf("Dumping %d cached paths\n", len(ctx.cache.paths))
@@ -710,23 +1959,125 @@ func (ctx *Context) DebugDumpPathCache() {
 	}
 }
 
+// DebugValidatePaths flattens the current path like Fill() does and returns warnings about likely
+// mistakes: sub-paths with near-zero area, open sub-paths which Fill() closes, holes with the same
+// winding as the sub-path containing them (so that they are filled), and duplicate consecutive points.
+// It returns nil if nothing looks wrong. The path cache and the current path are left untouched.
+func (ctx *Context) DebugValidatePaths() []string {
+	var warnings []string
+
+	// Duplicate points are merged by flattening, so look for them in the commands.
+	subPath := -1
+	var lastX, lastY float32
+	for i := 0; i < len(ctx.commands); {
+		switch nvgCommands(ctx.commands[i]) {
+		case nvgMOVETO:
+			subPath++
+			lastX, lastY = ctx.commands[i+1], ctx.commands[i+2]
+			i += 3
+		case nvgLINETO:
+			x, y := ctx.commands[i+1], ctx.commands[i+2]
+			if subPath >= 0 && ptEquals(x, y, lastX, lastY, ctx.distTol) {
+				warnings = append(warnings, fmt.Sprintf("sub-path %d: duplicate consecutive points at (%g, %g)", subPath, x, y))
+			}
+			lastX, lastY = x, y
+			i += 3
+		case nvgBEZIERTO:
+			lastX, lastY = ctx.commands[i+5], ctx.commands[i+6]
+			i += 7
+		case nvgQUADTO:
+			lastX, lastY = ctx.commands[i+3], ctx.commands[i+4]
+			i += 5
+		case nvgWINDING:
+			i += 2
+		default:
+			i++
+		}
+	}
+
+	// Flatten into a separate cache to keep the one of the context.
+	cache := ctx.cache
+	ctx.cache = nvgPathCache{maxDepth: cache.maxDepth}
+	ctx.flattenPaths()
+	flattened := ctx.cache
+	ctx.cache = cache
+
+	areas := make([]float32, len(flattened.paths))
+	for i := range flattened.paths {
+		path := &flattened.paths[i]
+		if path.count > 2 {
+			areas[i] = polyArea(flattened.points[path.first:], path.count)
+		}
+		if absF(areas[i]) < ctx.distTol*ctx.distTol*100 {
+			warnings = append(warnings, fmt.Sprintf("sub-path %d: near-zero area with %d points, it is invisible when filled", i, path.count))
+		}
+		if !path.closed {
+			warnings = append(warnings, fmt.Sprintf("sub-path %d: not closed, Fill() closes it with a straight line", i))
+		}
+	}
+	for i := range flattened.paths {
+		path := &flattened.paths[i]
+		if path.count < 3 {
+			continue
+		}
+		// The container is the smallest sub-path around the first point, a hole if nested at odd depth.
+		p := &flattened.points[path.first]
+		container, depth := -1, 0
+		for j := range flattened.paths {
+			other := &flattened.paths[j]
+			if j == i || other.count < 3 || absF(areas[j]) <= absF(areas[i]) {
+				continue
+			}
+			if polyContains(flattened.points[other.first:], other.count, p.x, p.y) {
+				depth++
+				if container < 0 || absF(areas[j]) < absF(areas[container]) {
+					container = j
+				}
+			}
+		}
+		if depth%2 == 1 && (areas[i] > 0) == (areas[container] > 0) {
+			warnings = append(warnings, fmt.Sprintf("sub-path %d: inside sub-path %d with the same winding, it is filled instead of being a hole (use PathWinding(Hole))", i, container))
+		}
+	}
+	return warnings
+}
+
 // Fill fills the current path with current fill style.
 func (ctx *Context) Fill() {
+	ctx.fill(ctx.params.edgeAntiAlias())
+}
+
+// FillStroke fills the current path with current fill style and then strokes it with current stroke style,
+// like Fill() followed by Stroke(), from the same flattened path.
+func (ctx *Context) FillStroke() {
+	ctx.flattenPaths()
+	ctx.fill(ctx.params.edgeAntiAlias())
+	ctx.Stroke()
+}
+
+func (ctx *Context) fill(fringe bool) {
 	state := ctx.getState()
 	fillPaint := state.fill
+	ctx.selectImageVariant(&fillPaint)
 	ctx.flattenPaths()
-
-	if ctx.params.edgeAntiAlias() {
-		ctx.cache.expandFill(ctx.fringeWidth, Miter, 2.4, ctx.fringeWidth)
-	} else {
-		ctx.cache.expandFill(0.0, Miter, 2.4, ctx.fringeWidth)
+	if ctx.isCulled(ctx.fringeWidth) {
+		return
 	}
 
-	// Apply global alpha
-	fillPaint.innerColor.A *= state.alpha
-	fillPaint.outerColor.A *= state.alpha
+	ctx.expandFill(fringe)
+
+	// Apply fill and global alpha
+	fillPaint.innerColor.A *= state.fillAlpha * state.alpha
+	fillPaint.outerColor.A *= state.fillAlpha * state.alpha
 
-	ctx.params.renderFill(&fillPaint, &state.scissor, ctx.fringeWidth, ctx.cache.bounds, ctx.cache.paths)
+	if ctx.inLayer {
+		paint, scissor, fringe, bounds, paths := fillPaint, state.scissor, ctx.fringeWidth, ctx.cache.bounds, copyPaths(ctx.cache.paths)
+		ctx.recordLayerCall(func(params nvgParams) {
+			params.renderFill(&paint, &scissor, fringe, bounds, paths)
+		})
+	} else {
+		ctx.params.renderFill(&fillPaint, &state.scissor, ctx.fringeWidth, ctx.cache.bounds, ctx.cache.paths)
+	}
 
 	// Count triangles
 	for i := 0; i < len(ctx.cache.paths); i++ {
@@ -737,12 +2088,45 @@ func (ctx *Context) Fill() {
 	}
 }
 
+// expandFill expands the flattened paths for filling, with anti-aliasing fringes if fringe is true.
+func (ctx *Context) expandFill(fringe bool) {
+	if fringe {
+		ctx.cache.expandFill(ctx.fringeWidth, Miter, 2.4, ctx.fringeWidth)
+	} else {
+		ctx.cache.expandFill(0.0, Miter, 2.4, ctx.fringeWidth)
+	}
+}
+
+// FillTriangles tessellates the current path like Fill() does and returns the fill vertexes of each sub-path,
+// without drawing anything. Each slice is a triangle fan. Fans of non-convex sub-paths overlap, so they have
+// to be drawn with a stencil (non-zero) like the GL backend does. No anti-aliasing fringe is generated.
+func (ctx *Context) FillTriangles() [][]Vertex {
+	ctx.flattenPaths()
+	ctx.expandFill(false)
+
+	result := make([][]Vertex, len(ctx.cache.paths))
+	for i := range ctx.cache.paths {
+		fills := ctx.cache.paths[i].fills
+		vertexes := make([]Vertex, len(fills))
+		for j, v := range fills {
+			vertexes[j] = Vertex{X: v.x, Y: v.y, U: v.u, V: v.v}
+		}
+		result[i] = vertexes
+	}
+	return result
+}
+
 // Stroke draws the current path with current stroke style.
 func (ctx *Context) Stroke() {
 	state := ctx.getState()
 	scale := state.xform.getAverageScale()
 	strokeWidth := clampF(state.strokeWidth*scale, 0.0, 200.0)
 	strokePaint := state.stroke
+	ctx.selectImageVariant(&strokePaint)
+	if state.strokeAlong {
+		strokePaint.setPaintColor(RGBAf(1, 1, 1, 1))
+		strokePaint.image = ctx.generatedImage(nvgGeneratedImage{colors: state.strokeColors})
+	}
 
 	if strokeWidth < ctx.fringeWidth {
 		// If the stroke width is less than pixel size, use alpha to emulate coverage.
@@ -753,22 +2137,39 @@ func (ctx *Context) Stroke() {
 		strokeWidth = ctx.fringeWidth
 	}
 
-	// Apply global alpha
-	strokePaint.innerColor.A *= state.alpha
-	strokePaint.outerColor.A *= state.alpha
+	// Apply stroke and global alpha
+	strokePaint.innerColor.A *= state.strokeAlpha * state.alpha
+	strokePaint.outerColor.A *= state.strokeAlpha * state.alpha
 
 	ctx.flattenPaths()
-	for _, path := range ctx.cache.paths {
-		if path.count == 1 {
-			panic("")
-		}
+	// Miter joins and square caps reach further than half the width.
+	if ctx.isCulled(strokeWidth*0.5*maxF(state.miterLimit, 2) + ctx.fringeWidth) {
+		return
+	}
+	offset, dashed := ctx.dashStroke(state, strokeWidth, scale)
+	if dashed {
+		// The cache has dashes now, so a following Fill() or Stroke() flattens the path again.
+		defer ctx.cache.clearPathCache()
+	}
+	ctx.expandStroke(state, strokeWidth, offset, ctx.params.edgeAntiAlias())
+	if state.strokeAlong {
+		ctx.renderStrokeAlongPath(&strokePaint, &state.scissor)
+		return
 	}
-	if ctx.params.edgeAntiAlias() {
-		ctx.cache.expandStroke(strokeWidth*0.5+ctx.fringeWidth*0.5, state.lineCap, state.lineJoin, state.miterLimit, ctx.fringeWidth, ctx.tessTol)
+	if ctx.inLayer {
+		paint, scissor, fringe, width, overlap, paths := strokePaint, state.scissor, ctx.fringeWidth, strokeWidth, state.strokeOverlap, copyPaths(ctx.cache.paths)
+		ctx.recordLayerCall(func(params nvgParams) {
+			if overlapper, ok := params.(nvgStrokeOverlapper); ok {
+				overlapper.setStrokeOverlapMode(overlap)
+			}
+			params.renderStroke(&paint, &scissor, fringe, width, paths)
+		})
 	} else {
-		ctx.cache.expandStroke(strokeWidth*0.5, state.lineCap, state.lineJoin, state.miterLimit, ctx.fringeWidth, ctx.tessTol)
+		if overlapper, ok := ctx.params.(nvgStrokeOverlapper); ok {
+			overlapper.setStrokeOverlapMode(state.strokeOverlap)
+		}
+		ctx.params.renderStroke(&strokePaint, &state.scissor, ctx.fringeWidth, strokeWidth, ctx.cache.paths)
 	}
-	ctx.params.renderStroke(&strokePaint, &state.scissor, ctx.fringeWidth, strokeWidth, ctx.cache.paths)
 
 	// Count triangles
 	for i := 0; i < len(ctx.cache.paths); i++ {
@@ -778,6 +2179,223 @@ func (ctx *Context) Stroke() {
 	}
 }
 
+// dashStroke splits the flattened paths into the dashes of the state and returns the offset of the
+// stroke align, which doesn't apply to dashes. dashed is true if the path cache has to be cleared
+// after the stroke is expanded.
+func (ctx *Context) dashStroke(state *nvgState, strokeWidth, scale float32) (offset float32, dashed bool) {
+	if len(state.lineDash) > 0 {
+		dashes := make([]float32, len(state.lineDash))
+		for i, dash := range state.lineDash {
+			dashes[i] = dash * scale
+		}
+		// Dashes move forward along the path as the time goes.
+		dashOffset := state.dashOffset - state.dashSpeed*ctx.frameTime
+		ctx.cache.dashPaths(dashes, dashOffset*scale, ctx.distTol)
+		return 0, true
+	}
+	switch state.strokeAlign {
+	case StrokeInner:
+		offset = -strokeWidth * 0.5
+	case StrokeOuter:
+		offset = strokeWidth * 0.5
+	}
+	return offset, false
+}
+
+// expandStroke expands the flattened paths for stroking with the style of the state, strokeWidth in device
+// space and the offset of the stroke align, with anti-aliasing fringes if fringe is true.
+func (ctx *Context) expandStroke(state *nvgState, strokeWidth, offset float32, fringe bool) {
+	if fringe {
+		ctx.cache.expandStroke(strokeWidth*0.5+ctx.fringeWidth*0.5, state.lineCap, state.lineJoin, state.miterLimit, ctx.fringeWidth, ctx.tessTol, offset)
+	} else {
+		// Without fringe, caps would be extended by the fringe quads which are drawn opaque.
+		ctx.cache.expandStroke(strokeWidth*0.5, state.lineCap, state.lineJoin, state.miterLimit, 0.0, ctx.tessTol, offset)
+	}
+}
+
+// StrokeTriangles tessellates the current path like Stroke() does with strokeWidth instead of the current
+// stroke width, and returns the geometry without drawing anything. The current line cap, line join, miter
+// limit, line dash and stroke align are applied. The strips of all sub-paths are returned as one list of
+// triangles, three vertexes each, in the coordinates of the transformed path. No anti-aliasing fringe is generated.
+func (ctx *Context) StrokeTriangles(strokeWidth float32) []Vertex {
+	state := ctx.getState()
+	scale := state.xform.getAverageScale()
+	strokeWidth = clampF(strokeWidth*scale, 0.0, 200.0)
+
+	ctx.flattenPaths()
+	offset, dashed := ctx.dashStroke(state, strokeWidth, scale)
+	if dashed {
+		defer ctx.cache.clearPathCache()
+	}
+	ctx.expandStroke(state, strokeWidth, offset, false)
+
+	var result []Vertex
+	for i := range ctx.cache.paths {
+		strokes := ctx.cache.paths[i].strokes
+		for j := 0; j+2 < len(strokes); j++ {
+			// Every other triangle of a strip is reversed.
+			a, b, c := &strokes[j], &strokes[j+1], &strokes[j+2]
+			if j%2 == 1 {
+				a, b = b, a
+			}
+			result = append(result,
+				Vertex{X: a.x, Y: a.y, U: a.u, V: a.v},
+				Vertex{X: b.x, Y: b.y, U: b.u, V: b.v},
+				Vertex{X: c.x, Y: c.y, U: c.u, V: c.v})
+		}
+	}
+	return result
+}
+
+// EstimateFillTriangles returns the number of triangles Fill() would draw for the current path, with the
+// anti-aliasing fringes, without drawing it. The path is kept, so it can be drawn or simplified afterwards.
+func (ctx *Context) EstimateFillTriangles() int {
+	ctx.flattenPaths()
+	ctx.expandFill(ctx.params.edgeAntiAlias())
+	count := 0
+	for i := range ctx.cache.paths {
+		path := &ctx.cache.paths[i]
+		count += maxI(len(path.fills)-2, 0) + maxI(len(path.strokes)-2, 0)
+	}
+	return count
+}
+
+// EstimateStrokeTriangles returns the number of triangles Stroke() would draw for the current path with
+// strokeWidth and the other stroke style of the state, without drawing it. The path is kept.
+func (ctx *Context) EstimateStrokeTriangles(strokeWidth float32) int {
+	state := ctx.getState()
+	scale := state.xform.getAverageScale()
+	strokeWidth = maxF(clampF(strokeWidth*scale, 0.0, 200.0), ctx.fringeWidth)
+
+	ctx.flattenPaths()
+	offset, dashed := ctx.dashStroke(state, strokeWidth, scale)
+	if dashed {
+		defer ctx.cache.clearPathCache()
+	}
+	ctx.expandStroke(state, strokeWidth, offset, ctx.params.edgeAntiAlias())
+	count := 0
+	for i := range ctx.cache.paths {
+		count += maxI(len(ctx.cache.paths[i].strokes)-2, 0)
+	}
+	return count
+}
+
+// renderStrokeAlongPath renders expanded strokes as triangle strips textured by the ramp image of the paint.
+// Texture coordinate follows the distance along the strips, which have a pair of vertexes at each step.
+func (ctx *Context) renderStrokeAlongPath(paint *Paint, scissor *nvgScissor) {
+	var total float32
+	for i := range ctx.cache.paths {
+		strokes := ctx.cache.paths[i].strokes
+		for j := 2; j+1 < len(strokes); j += 2 {
+			total += strokeStepLength(strokes, j)
+		}
+	}
+	if total <= 0 {
+		total = 1
+	}
+	var distance float32
+	for i := range ctx.cache.paths {
+		strokes := ctx.cache.paths[i].strokes
+		for j := 0; j+1 < len(strokes); j += 2 {
+			if j >= 2 {
+				distance += strokeStepLength(strokes, j)
+			}
+			// Sample between the centers of the two texels of the ramp.
+			u := 0.25 + 0.5*clampF(distance/total, 0, 1)
+			strokes[j].u, strokes[j].v = u, 0.5
+			strokes[j+1].u, strokes[j+1].v = u, 0.5
+		}
+		if len(strokes) > 0 {
+			ctx.renderTriangleStrip(paint, scissor, strokes)
+			ctx.strokeTriCount += len(strokes) - 2
+			ctx.drawCallCount++
+		}
+	}
+}
+
+// renderTriangleStrip passes the strip to the backend, or records it when drawing in a layer.
+func (ctx *Context) renderTriangleStrip(paint *Paint, scissor *nvgScissor, vertexes []nvgVertex) {
+	if !ctx.inLayer {
+		ctx.params.renderTriangleStrip(paint, scissor, vertexes)
+		return
+	}
+	layerPaint, layerScissor, vertexes := *paint, *scissor, append([]nvgVertex(nil), vertexes...)
+	ctx.recordLayerCall(func(params nvgParams) {
+		params.renderTriangleStrip(&layerPaint, &layerScissor, vertexes)
+	})
+}
+
+func (ctx *Context) renderTriangles(paint *Paint, scissor *nvgScissor, vertexes []nvgVertex) {
+	if !ctx.inLayer {
+		ctx.params.renderTriangles(paint, scissor, vertexes)
+		return
+	}
+	layerPaint, layerScissor, vertexes := *paint, *scissor, append([]nvgVertex(nil), vertexes...)
+	ctx.recordLayerCall(func(params nvgParams) {
+		params.renderTriangles(&layerPaint, &layerScissor, vertexes)
+	})
+}
+
+// strokeStepLength returns the distance between centers of vertex pairs j-2, j-1 and j, j+1 of stroke strip.
+func strokeStepLength(strokes []nvgVertex, j int) float32 {
+	dx := (strokes[j].x + strokes[j+1].x - strokes[j-2].x - strokes[j-1].x) * 0.5
+	dy := (strokes[j].y + strokes[j+1].y - strokes[j-2].y - strokes[j-1].y) * 0.5
+	return sqrtF(dx*dx + dy*dy)
+}
+
+// generatedImage returns small image of the colors, 2x1 ramp for gradients along strokes or 2x2 checker.
+// Images which are not used in a frame are deleted at the beginning of the next frame.
+func (ctx *Context) generatedImage(key nvgGeneratedImage) int {
+	if ctx.genImagesUsed == nil {
+		ctx.genImagesUsed = make(map[nvgGeneratedImage]bool)
+	}
+	ctx.genImagesUsed[key] = true
+	if img, ok := ctx.genImages[key]; ok {
+		return img
+	}
+	if ctx.genImages == nil {
+		ctx.genImages = make(map[nvgGeneratedImage]int)
+	}
+	var img int
+	if key.dot {
+		img = ctx.params.renderCreateTexture(nvgTextureRGBA, nvgDotImageSize, nvgDotImageSize, ImageGenerateMipmaps, dotImageData())
+	} else if key.checker {
+		data := paletteData([]Color{key.colors[0], key.colors[1], key.colors[1], key.colors[0]})[:16]
+		img = ctx.params.renderCreateTexture(nvgTextureRGBA, 2, 2, ImageRepeatX|ImageRepeatY|ImageNearest, data)
+	} else {
+		img = ctx.params.renderCreateTexture(nvgTextureRGBA, 2, 1, 0, paletteData(key.colors[:])[:8])
+	}
+	ctx.genImages[key] = img
+	return img
+}
+
+// dotImageData returns white RGBA pixels of an anti-aliased disk, which touches the image border with one pixel margin.
+func dotImageData() []byte {
+	const size = nvgDotImageSize
+	data := make([]byte, size*size*4)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx, dy := float32(x)+0.5-size*0.5, float32(y)+0.5-size*0.5
+			coverage := clampF(size*0.5-1-sqrtF(dx*dx+dy*dy)+0.5, 0, 1)
+			i := (y*size + x) * 4
+			data[i], data[i+1], data[i+2], data[i+3] = 255, 255, 255, uint8(coverage*255+0.5)
+		}
+	}
+	return data
+}
+
+func (ctx *Context) pruneGeneratedImages() {
+	for key, img := range ctx.genImages {
+		if !ctx.genImagesUsed[key] {
+			ctx.params.renderDeleteTexture(img)
+			delete(ctx.genImages, key)
+		}
+	}
+	for key := range ctx.genImagesUsed {
+		delete(ctx.genImagesUsed, key)
+	}
+}
+
 // CreateFont creates font by loading it from the disk from specified file name.
 // Returns handle to the font.
 func (ctx *Context) CreateFont(name, filePath string) int {
@@ -790,6 +2408,20 @@ func (ctx *Context) CreateFontFromMemory(name string, data []byte, freeData uint
 	return ctx.fs.AddFontFromMemory(name, data, freeData)
 }
 
+// CreateFontFromReader creates font by reading it fully from the reader, e.g. a file of embed.FS.
+// Returns handle to the font, or -1 and the error if reading or loading fails.
+func (ctx *Context) CreateFontFromReader(name string, r io.Reader, freeData uint8) (int, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fontstashmini.INVALID, err
+	}
+	font := ctx.CreateFontFromMemory(name, data, freeData)
+	if font == fontstashmini.INVALID {
+		return fontstashmini.INVALID, errors.New("can't load font " + name)
+	}
+	return font, nil
+}
+
 // FindFont finds a loaded font of specified name, and returns handle to it, or -1 if the font is not found.
 func (ctx *Context) FindFont(name string) int {
 	return ctx.fs.GetFontByName(name)
@@ -808,6 +2440,33 @@ func (ctx *Context) FontSize() float32 {
 	return ctx.getState().fontSize
 }
 
+// SetFontSizePt sets the font size of current text style in points, converted to pixels with the DPI set by SetDPI().
+func (ctx *Context) SetFontSizePt(pt float32) {
+	ctx.SetFontSize(pt * ctx.dpi / 72)
+}
+
+// FontSizePt gets the font size of current text style in points.
+func (ctx *Context) FontSizePt() float32 {
+	return ctx.getState().fontSize * 72 / ctx.dpi
+}
+
+// SetDPI sets the resolution used by SetFontSizePt() and FontSizePt(), default is 96 (1pt is 4/3px).
+// Font sizes are in the same units as coordinates, which BeginFrame() scales by devicePixelRatio,
+// so dpi is the logical resolution of those units and not of the device: on a Hi-DPI screen keep 96
+// and let devicePixelRatio scale the text, or the points would be scaled twice.
+// Zero, negative, NaN and infinite values are ignored.
+func (ctx *Context) SetDPI(dpi float32) {
+	if !isFiniteF(dpi) || dpi <= 0 {
+		return
+	}
+	ctx.dpi = dpi
+}
+
+// DPI gets the resolution used to convert font sizes in points.
+func (ctx *Context) DPI() float32 {
+	return ctx.dpi
+}
+
 // SetFontBlur sets the font blur of current text style.
 func (ctx *Context) SetFontBlur(blur float32) {
 	ctx.getState().fontBlur = blur
@@ -828,6 +2487,44 @@ func (ctx *Context) TextLetterSpacing() float32 {
 	return ctx.getState().letterSpacing
 }
 
+// SetTabStops sets the distances between tab stops of current text style, the last one repeats.
+// For example []float32{100} puts a stop every 100 units and []float32{80, 40} puts them at 80, 120, 160...
+// Tab stops are measured from the text position, or from the beginning of the row in TextBox() and
+// TextBreakLines(). A tab moves the pen to the next stop. Without tab stops (default), a tab is a glyph.
+// Widths with a zero, negative, NaN or infinite value remove the tab stops, like an empty slice.
+func (ctx *Context) SetTabStops(widths []float32) {
+	state := ctx.getState()
+	for _, width := range widths {
+		if !isFiniteF(width) || width <= 0 {
+			state.tabStops = nil
+			return
+		}
+	}
+	if len(widths) == 0 {
+		state.tabStops = nil
+		return
+	}
+	state.tabStops = append([]float32(nil), widths...)
+}
+
+// TabStops gets the distances between tab stops of current text style.
+func (ctx *Context) TabStops() []float32 {
+	return append([]float32(nil), ctx.getState().tabStops...)
+}
+
+// SetTextDecoration sets the lines drawn with text of current text style. Text(), TextRune() and
+// the rows of TextBox() draw them after the glyphs with the current fill style, across the text advance.
+// The underline is half the descender below the baseline, the strikethrough is at 30% of the ascender,
+// and they are 1/16 of the font size thick.
+func (ctx *Context) SetTextDecoration(dec TextDecoration) {
+	ctx.getState().decoration = dec
+}
+
+// TextDecoration gets the lines drawn with text of current text style.
+func (ctx *Context) TextDecoration() TextDecoration {
+	return ctx.getState().decoration
+}
+
 // SetTextLineHeight sets the line height of current text style.
 func (ctx *Context) SetTextLineHeight(lineHeight float32) {
 	ctx.getState().lineHeight = lineHeight
@@ -849,8 +2546,15 @@ func (ctx *Context) TextAlign() Align {
 }
 
 // SetFontFaceID sets the font face based on specified id of current text style.
-func (ctx *Context) SetFontFaceID(font int) {
+// fontstashmini.INVALID unsets the font face. Returns false and unsets the font face if the id isn't
+// a loaded font.
+func (ctx *Context) SetFontFaceID(font int) bool {
+	if font != fontstashmini.INVALID && (font < 0 || font >= ctx.fs.FontCount()) {
+		ctx.getState().fontID = fontstashmini.INVALID
+		return false
+	}
 	ctx.getState().fontID = font
+	return true
 }
 
 // FontFaceID gets the font face id of current text style.
@@ -858,23 +2562,119 @@ func (ctx *Context) FontFaceID() int {
 	return ctx.getState().fontID
 }
 
-// SetFontFace sets the font face based on specified name of current text style.
-func (ctx *Context) SetFontFace(font string) {
-	ctx.getState().fontID = ctx.fs.GetFontByName(font)
+// SetFontFace sets the font face based on specified name of current text style.
+func (ctx *Context) SetFontFace(font string) {
+	ctx.getState().fontID = ctx.fs.GetFontByName(font)
+}
+
+// FontFace gets the font face name of current text style.
+func (ctx *Context) FontFace() string {
+	return ctx.fs.GetFontName()
+}
+
+// Text draws text string at specified location. If end is specified only the sub-string up to the end is drawn.
+func (ctx *Context) Text(x, y float32, str string) float32 {
+	return ctx.TextRune(x, y, []rune(str))
+}
+
+// TextN draws the first n runes of text string at specified location (n is clamped to the length of the string).
+// Returns the horizontal position where the next glyph would start, e.g. for a caret of typewriter animation.
+// Text align is applied to the drawn runes, so use AlignLeft to keep the revealed glyphs in place.
+func (ctx *Context) TextN(x, y float32, str string, n int) float32 {
+	runes := []rune(str)
+	return ctx.TextRune(x, y, runes[:clampI(n, 0, len(runes))])
+}
+
+// TextRune is an alternate version of Text that accepts rune slice.
+func (ctx *Context) TextRune(x, y float32, runes []rune) float32 {
+	return ctx.textRunes(x, y, runes, nil)
+}
+
+// TextTransformed draws text string like Text(), but transforms each glyph by the matrix returned by glyphXform
+// before the current transform. The matrix is applied around the glyph origin on the baseline, i is the index of
+// the glyph in the string and advanceX is the horizontal distance of the glyph origin from x.
+// The glyphs are laid out as usual, so the transforms don't affect the positions of the following glyphs.
+// Returns the horizontal advance of the text.
+func (ctx *Context) TextTransformed(x, y float32, str string, glyphXform func(i int, advanceX float32) TransformMatrix) float32 {
+	return ctx.textRunes(x, y, []rune(str), glyphXform)
+}
+
+// SetMissingGlyphHandler sets the function which is called for the runes the current font has no glyph for,
+// which are drawn as the missing glyph (.notdef) of the font. It is called by Text() and the other text drawing
+// functions, and by TextBounds(), once per missing rune in each call (e.g. once for all rows of TextBox()),
+// with the font id. Control characters (e.g. new lines) are not reported. Nil removes the handler.
+func (ctx *Context) SetMissingGlyphHandler(handler func(r rune, fontID int)) {
+	ctx.missingGlyph = handler
+}
+
+// reportMissingGlyphs calls the missing glyph handler for the runes the current font of the font stash has
+// no glyph for, once for each rune.
+func (ctx *Context) reportMissingGlyphs(runes []rune) {
+	if ctx.missingGlyph == nil {
+		return
+	}
+	var reported []rune
+next:
+	for _, r := range runes {
+		if unicode.IsControl(r) || ctx.fs.HasGlyph(r) {
+			continue
+		}
+		for _, p := range reported {
+			if p == r {
+				continue next
+			}
+		}
+		reported = append(reported, r)
+		ctx.missingGlyph(r, ctx.getState().fontID)
+	}
 }
 
-// FontFace gets the font face name of current text style.
-func (ctx *Context) FontFace() string {
-	return ctx.fs.GetFontName()
+// TextRenderScale returns the scale from local font sizes to rasterized pixels which text drawing uses: the
+// average scale of the current transform, quantized to 0.01 and limited to 4, times the device pixel ratio.
+// Glyphs of the current font size are rasterized at fontSize*TextRenderScale() pixels.
+func (ctx *Context) TextRenderScale() float32 {
+	return ctx.getState().getFontScale() * ctx.devicePxRatio
 }
 
-// Text draws text string at specified location. If end is specified only the sub-string up to the end is drawn.
-func (ctx *Context) Text(x, y float32, str string) float32 {
-	return ctx.TextRune(x, y, []rune(str))
+// PreloadGlyphs rasterizes the runes of the font at size into the font atlas ahead of time, like Text() does
+// when it draws them for the first time, so that it doesn't happen when the text appears at a later frame.
+// The glyphs are rasterized for the current transform, device pixel ratio and font blur, and new atlas
+// pages are created as needed. Returns the number of glyphs which are cached.
+func (ctx *Context) PreloadGlyphs(fontID int, size float32, runes []rune) int {
+	state := ctx.getState()
+	if fontID < 0 || fontID >= ctx.fs.FontCount() || size <= 0 {
+		return 0
+	}
+	scale := state.getFontScale() * ctx.devicePxRatio
+	ctx.fs.SetSize(size * scale)
+	ctx.fs.SetSpacing(0)
+	ctx.fs.SetTabStops(nil, scale)
+	ctx.fs.SetBlur(state.fontBlur * scale)
+	ctx.fs.SetAlign(fontstashmini.FONSAlign(AlignLeft | AlignBaseline))
+	ctx.fs.SetFont(fontID)
+
+	iter := ctx.fs.TextIterForRunes(0, 0, runes)
+	count := 0
+	for {
+		if _, ok := iter.Next(); !ok {
+			break
+		}
+		if iter.PrevGlyph == nil || iter.PrevGlyph.Index == -1 {
+			// The new atlas page doesn't have the glyphs cached so far, start again.
+			if !ctx.allocTextAtlas() {
+				break // no memory :(
+			}
+			iter = ctx.fs.TextIterForRunes(0, 0, runes)
+			count = 0
+			continue
+		}
+		count++
+	}
+	ctx.flushTextTexture()
+	return count
 }
 
-// TextRune is an alternate version of Text that accepts rune slice.
-func (ctx *Context) TextRune(x, y float32, runes []rune) float32 {
+func (ctx *Context) textRunes(x, y float32, runes []rune, glyphXform func(i int, advanceX float32) TransformMatrix) float32 {
 	state := ctx.getState()
 	scale := state.getFontScale() * ctx.devicePxRatio
 	invScale := 1.0 / scale
@@ -884,9 +2684,27 @@ func (ctx *Context) TextRune(x, y float32, runes []rune) float32 {
 
 	ctx.fs.SetSize(state.fontSize * scale)
 	ctx.fs.SetSpacing(state.letterSpacing * scale)
+	ctx.fs.SetTabStops(state.tabStops, scale)
 	ctx.fs.SetBlur(state.fontBlur * scale)
 	ctx.fs.SetAlign(fontstashmini.FONSAlign(state.textAlign))
 	ctx.fs.SetFont(state.fontID)
+	ctx.reportMissingGlyphs(runes)
+
+	if recorder, ok := ctx.params.(nvgTextRecorder); ok {
+		paint := state.fill
+		paint.innerColor.A *= state.alpha
+		paint.outerColor.A *= state.alpha
+		if ctx.inLayer {
+			paint, scissor, xform, x, y := paint, state.scissor, state.xform, x, y
+			fontName, fontSize, letterSpacing, align := ctx.fs.GetFontName(), state.fontSize, state.letterSpacing, state.textAlign
+			runes := append([]rune(nil), runes...)
+			ctx.recordLayerCall(func(params nvgParams) {
+				params.(nvgTextRecorder).renderText(&paint, &scissor, xform, x, y, fontName, fontSize, letterSpacing, align, runes)
+			})
+		} else {
+			recorder.renderText(&paint, &state.scissor, state.xform, x, y, ctx.fs.GetFontName(), state.fontSize, state.letterSpacing, state.textAlign, runes)
+		}
+	}
 
 	vertexCount := maxI(2, len(runes)) * 4 // conservative estimate.
 	vertexes := ctx.cache.allocVertexes(vertexCount)
@@ -894,6 +2712,7 @@ func (ctx *Context) TextRune(x, y float32, runes []rune) float32 {
 	iter := ctx.fs.TextIterForRunes(x*scale, y*scale, runes)
 	prevIter := iter
 	index := 0
+	startX, startY := iter.NextX, iter.NextY
 
 	for {
 		quad, ok := iter.Next()
@@ -916,11 +2735,17 @@ func (ctx *Context) TextRune(x, y float32, runes []rune) float32 {
 			}
 		}
 		prevIter = iter
+		xform := state.xform
+		if glyphXform != nil {
+			ox, oy := iter.X*invScale, iter.Y*invScale
+			m := glyphXform(iter.CurrentIndex, ox-x)
+			xform = TranslateMatrix(-ox, -oy).Multiply(m).Multiply(TranslateMatrix(ox, oy)).Multiply(xform)
+		}
 		// Transform corners.
-		c0, c1 := state.xform.TransformPoint(quad.X0*invScale, quad.Y0*invScale)
-		c2, c3 := state.xform.TransformPoint(quad.X1*invScale, quad.Y0*invScale)
-		c4, c5 := state.xform.TransformPoint(quad.X1*invScale, quad.Y1*invScale)
-		c6, c7 := state.xform.TransformPoint(quad.X0*invScale, quad.Y1*invScale)
+		c0, c1 := xform.TransformPoint(quad.X0*invScale, quad.Y0*invScale)
+		c2, c3 := xform.TransformPoint(quad.X1*invScale, quad.Y0*invScale)
+		c4, c5 := xform.TransformPoint(quad.X1*invScale, quad.Y1*invScale)
+		c6, c7 := xform.TransformPoint(quad.X0*invScale, quad.Y1*invScale)
 		//log.Printf("quad(%ctx) x0=%d, x1=%d, y0=%d, y1=%d, s0=%d, s1=%d, t0=%d, t1=%d\n", iter.CodePoint, int(quad.X0), int(quad.X1), int(quad.Y0), int(quad.Y1), int(1024*quad.S0), int(quad.S1*1024), int(quad.T0*1024), int(quad.T1*1024))
 		// Create triangles
 		if index+4 <= vertexCount {
@@ -933,18 +2758,223 @@ func (ctx *Context) TextRune(x, y float32, runes []rune) float32 {
 	}
 	ctx.flushTextTexture()
 	ctx.renderText(vertexes[:index])
-	return iter.X
+	if state.decoration != DecorationNone && glyphXform == nil {
+		ascender, descender, _ := ctx.fs.VerticalMetrics()
+		x0, x1, baseline := startX*invScale, iter.NextX*invScale, startY*invScale
+		thickness := state.fontSize / 16
+		if state.decoration&DecorationUnderline != 0 {
+			ctx.fillRectKeepingPath(x0, baseline-descender*invScale*0.5-thickness*0.5, x1-x0, thickness)
+		}
+		if state.decoration&DecorationStrikethrough != 0 {
+			ctx.fillRectKeepingPath(x0, baseline-ascender*invScale*0.3-thickness*0.5, x1-x0, thickness)
+		}
+	}
+	return iter.NextX * invScale
+}
+
+// fillRectKeepingPath fills the rectangle with the current fill style, and restores the current path afterwards.
+func (ctx *Context) fillRectKeepingPath(x, y, w, h float32) {
+	commands := append([]float32(nil), ctx.commands...)
+	commandX, commandY, lastPointIdx, moveToIdx := ctx.commandX, ctx.commandY, ctx.lastPointIdx, ctx.moveToIdx
+	ctx.BeginPath()
+	ctx.Rect(x, y, w, h)
+	ctx.Fill()
+	ctx.commands = append(ctx.commands[:0], commands...)
+	ctx.commandX, ctx.commandY, ctx.lastPointIdx, ctx.moveToIdx = commandX, commandY, lastPointIdx, moveToIdx
+	ctx.cache.clearPathCache()
+}
+
+// DrawGlyphRun draws pre-shaped glyphs of the current font with the baseline origin at (x,y).
+// Each glyph is drawn at the pen position plus its offsets, then the pen is moved by its advance.
+// Text align and letter spacing are not applied since the glyphs are already positioned.
+// Returns the horizontal position of the pen after the run.
+func (ctx *Context) DrawGlyphRun(x, y float32, run []PositionedGlyph) float32 {
+	state := ctx.getState()
+	scale := state.getFontScale() * ctx.devicePxRatio
+	invScale := 1.0 / scale
+	if state.fontID == fontstashmini.INVALID {
+		return x
+	}
+
+	ctx.fs.SetSize(state.fontSize * scale)
+	ctx.fs.SetSpacing(0)
+	ctx.fs.SetBlur(state.fontBlur * scale)
+	ctx.fs.SetAlign(fontstashmini.ALIGN_LEFT | fontstashmini.ALIGN_BASELINE)
+	ctx.fs.SetFont(state.fontID)
+
+	vertexCount := maxI(2, len(run)) * 4
+	vertexes := ctx.cache.allocVertexes(vertexCount)
+	index := 0
+
+	penX := x
+	for _, glyph := range run {
+		gx := (penX + glyph.XOffset) * scale
+		gy := (y + glyph.YOffset) * scale
+		penX += glyph.XAdvance
+		quad, ok := ctx.fs.GlyphQuadByIndex(glyph.GlyphID, gx, gy)
+		if !ok {
+			// Render glyphs in the current atlas before moving to the next one.
+			ctx.flushTextTexture()
+			ctx.renderText(vertexes[:index])
+			index = 0
+			if !ctx.allocTextAtlas() {
+				break // no memory :(
+			}
+			if quad, ok = ctx.fs.GlyphQuadByIndex(glyph.GlyphID, gx, gy); !ok {
+				break
+			}
+		}
+		c0, c1 := state.xform.TransformPoint(quad.X0*invScale, quad.Y0*invScale)
+		c2, c3 := state.xform.TransformPoint(quad.X1*invScale, quad.Y0*invScale)
+		c4, c5 := state.xform.TransformPoint(quad.X1*invScale, quad.Y1*invScale)
+		c6, c7 := state.xform.TransformPoint(quad.X0*invScale, quad.Y1*invScale)
+		(&vertexes[index]).set(c2, c3, quad.S1, quad.T0)
+		(&vertexes[index+1]).set(c0, c1, quad.S0, quad.T0)
+		(&vertexes[index+2]).set(c4, c5, quad.S1, quad.T1)
+		(&vertexes[index+3]).set(c6, c7, quad.S0, quad.T1)
+		index += 4
+	}
+	ctx.flushTextTexture()
+	ctx.renderText(vertexes[:index])
+	return penX
+}
+
+// TextStroke draws text string at specified location with an outline of outlineWidth in outlineColor behind it.
+// The outline is made by drawing the glyphs several times around a ring of outlineWidth radius,
+// then the text is drawn with the current fill style on top.
+// Returns the horizontal advance like Text().
+func (ctx *Context) TextStroke(x, y float32, str string, outlineColor Color, outlineWidth float32) float32 {
+	runes := []rune(str)
+	if outlineWidth > 0 {
+		state := ctx.getState()
+		fill := state.fill
+		state.fill.setPaintColor(outlineColor)
+		// Missing glyphs are reported by the text on top, not by each copy of the outline.
+		missingGlyph := ctx.missingGlyph
+		ctx.missingGlyph = nil
+		// Enough samples to not leave gaps between the copies (about one per pixel of the ring).
+		n := clampI(ceilF(2*PI*outlineWidth*ctx.devicePxRatio), 8, 32)
+		for i := 0; i < n; i++ {
+			s, c := sinCosF(2 * PI * float32(i) / float32(n))
+			ctx.TextRune(x+c*outlineWidth, y+s*outlineWidth, runes)
+		}
+		state.fill = fill
+		ctx.missingGlyph = missingGlyph
+	}
+	return ctx.TextRune(x, y, runes)
 }
 
 // TextBox draws multi-line text string at specified location wrapped at the specified width. If end is specified only the sub-string up to the end is drawn.
 // White space is stripped at the beginning of the rows, the text is split at word boundaries or when new-line characters are encountered.
 // Words longer than the max width are slit at nearest character (i.e. no hyphenation).
+// Vertical align applies to the whole block of rows: AlignMiddle centers it at y and AlignBottom puts the last row at y.
 // Draws text string at specified location. If end is specified only the sub-string up to the end is drawn.
 func (ctx *Context) TextBox(x, y, breakRowWidth float32, str string) {
 	state := ctx.getState()
 	if state.fontID == fontstashmini.INVALID {
 		return
 	}
+	lines := ctx.TextBoxLines(x, y, breakRowWidth, str)
+	ctx.drawTextBoxLines(lines, ctx.scissorBounds())
+}
+
+// TextBoxClipped draws multi-line text string like TextBox() but only the rows which overlap the rectangle
+// (clipX,clipY)-(clipX+clipW,clipY+clipH) in local coordinate space, in addition to the current scissor.
+// Pixels of the drawn rows are not clipped, so use it with a matching scissor to clip them.
+// It is useful for long scrolled text where only a few rows are visible.
+func (ctx *Context) TextBoxClipped(x, y, breakRowWidth float32, str string, clipX, clipY, clipW, clipH float32) {
+	state := ctx.getState()
+	if state.fontID == fontstashmini.INVALID {
+		return
+	}
+	lines := ctx.TextBoxLines(x, y, breakRowWidth, str)
+	clip := transformRectBounds(state.xform, clipX, clipY, clipW, clipH)
+	if scissor := ctx.scissorBounds(); scissor != nil {
+		clip = IntersectRects(clip[0], clip[1], clip[2], clip[3], scissor[0], scissor[1], scissor[2], scissor[3])
+	}
+	ctx.drawTextBoxLines(lines, &clip)
+}
+
+// drawTextBoxLines draws rows of TextBoxLines() which overlap the clip rectangle {x, y, w, h} in device space.
+// All rows are drawn if clip is nil.
+func (ctx *Context) drawTextBoxLines(lines []TextLineLayout, clip *[4]float32) {
+	state := ctx.getState()
+	ascender, descender, _ := ctx.TextMetrics()
+	// Glyphs may overhang the ascender, descender and the row width a bit.
+	margin := (ascender-descender)*0.5 + state.fontBlur
+
+	// Rows are already aligned horizontally by TextBoxLines
+	oldAlign := state.textAlign
+	state.textAlign = AlignLeft | (state.textAlign & (AlignTop | AlignMiddle | AlignBottom | AlignBaseline))
+	var drawn []TextLineLayout
+	var runes []rune
+	for _, line := range lines {
+		if clip != nil {
+			top := line.Baseline - ascender - margin
+			bottom := line.Baseline - descender + margin
+			row := transformRectBounds(state.xform, line.X-margin, top, line.Width+margin*2, bottom-top)
+			if overlap := IntersectRects(row[0], row[1], row[2], row[3], clip[0], clip[1], clip[2], clip[3]); overlap[2] <= 0 || overlap[3] <= 0 {
+				continue
+			}
+		}
+		drawn = append(drawn, line)
+		runes = append(runes, line.Runes[line.StartIndex:line.EndIndex]...)
+	}
+	// Missing glyphs are reported once for all drawn rows, not by each row.
+	ctx.fs.SetFont(state.fontID)
+	ctx.reportMissingGlyphs(runes)
+	missingGlyph := ctx.missingGlyph
+	ctx.missingGlyph = nil
+	for _, line := range drawn {
+		ctx.TextRune(line.X, line.Y, line.Runes[line.StartIndex:line.EndIndex])
+	}
+	ctx.missingGlyph = missingGlyph
+	state.textAlign = oldAlign
+}
+
+// scissorBounds returns the bounding box {x, y, w, h} of the current scissor in device space, or nil if
+// scissoring is disabled.
+func (ctx *Context) scissorBounds() *[4]float32 {
+	scissor := &ctx.getState().scissor
+	if scissor.extent[0] < -0.5 || scissor.extent[1] < -0.5 {
+		return nil
+	}
+	xform := scissor.xform
+	ex := scissor.extent[0]*absF(xform[0]) + scissor.extent[1]*absF(xform[2])
+	ey := scissor.extent[0]*absF(xform[1]) + scissor.extent[1]*absF(xform[3])
+	return &[4]float32{xform[4] - ex, xform[5] - ey, ex * 2, ey * 2}
+}
+
+// TextBoxLines calculates the layout of multi-line text string which TextBox draws with the same parameters.
+// It returns one TextLineLayout per wrapped row which contains the position where the row is drawn
+// (already shifted for center/right alignment), its baseline and the rune range of the row.
+// Measured values are returned in local coordinate space.
+func (ctx *Context) TextBoxLines(x, y, breakRowWidth float32, str string) []TextLineLayout {
+	lines, _ := ctx.textBoxLines(x, y, breakRowWidth, str, -1)
+	return lines
+}
+
+// TextBoxLimited draws multi-line text string like TextBox() but at most maxLines rows. If the text has more
+// rows, the last drawn row is shortened to fit breakRowWidth with an ellipsis (…) at its end. The vertical
+// align applies to the drawn rows. It returns true if the text was truncated.
+func (ctx *Context) TextBoxLimited(x, y, breakRowWidth float32, str string, maxLines int) bool {
+	state := ctx.getState()
+	if state.fontID == fontstashmini.INVALID {
+		return false
+	}
+	lines, truncated := ctx.textBoxLines(x, y, breakRowWidth, str, maxI(maxLines, 0))
+	ctx.drawTextBoxLines(lines, ctx.scissorBounds())
+	return truncated
+}
+
+// textBoxLines calculates the layout of TextBoxLines() with at most maxLines rows, or all of them if maxLines
+// is negative. It also returns whether rows were left out, then the Runes of the last row are its own copy
+// which ends with an ellipsis.
+func (ctx *Context) textBoxLines(x, y, breakRowWidth float32, str string, maxLines int) ([]TextLineLayout, bool) {
+	state := ctx.getState()
+	if state.fontID == fontstashmini.INVALID {
+		return nil, false
+	}
 	runes := []rune(str)
 
 	oldAlign := state.textAlign
@@ -960,22 +2990,93 @@ func (ctx *Context) TextBox(x, y, breakRowWidth float32, str string) {
 	vAlign := state.textAlign & (AlignTop | AlignMiddle | AlignBottom | AlignBaseline)
 	state.textAlign = AlignLeft | vAlign
 
-	_, _, lineH := ctx.TextMetrics()
+	ascender, descender, lineH := ctx.TextMetrics()
+	var baseline float32
+	if vAlign&AlignBaseline != 0 {
+		baseline = 0
+	} else if vAlign&AlignTop != 0 {
+		baseline = ascender
+	} else if vAlign&AlignMiddle != 0 {
+		baseline = (ascender + descender) * 0.5
+	} else if vAlign&AlignBottom != 0 {
+		baseline = descender
+	}
 
+	rows := ctx.TextBreakLinesRune(runes, breakRowWidth)
+	truncated := maxLines >= 0 && len(rows) > maxLines
+	if truncated {
+		rows = rows[:maxLines]
+		if maxLines > 0 {
+			rows[maxLines-1] = ctx.ellipsizeRow(rows[maxLines-1], breakRowWidth)
+		}
+	}
 	state.textAlign = oldAlign
+	y -= textBoxAlignOffset(vAlign, len(rows), lineH*state.lineHeight)
 
-	for _, row := range ctx.TextBreakLinesRune(runes, breakRowWidth) {
-		text := string(runes[row.StartIndex:row.EndIndex])
+	lines := make([]TextLineLayout, 0, len(rows))
+	for _, row := range rows {
+		width := ctx.textRowWidth(row)
+		var dx float32
 		switch hAlign {
-		case AlignLeft:
-			ctx.Text(x, y, text)
 		case AlignCenter:
-			ctx.Text(x+breakRowWidth*0.5-row.Width*0.5, y, text)
+			dx = breakRowWidth*0.5 - width*0.5
 		case AlignRight:
-			ctx.Text(x+breakRowWidth-row.Width, y, text)
+			dx = breakRowWidth - width
 		}
+		lines = append(lines, TextLineLayout{
+			Runes:      row.Runes,
+			StartIndex: row.StartIndex,
+			EndIndex:   row.EndIndex,
+			X:          x + dx,
+			Y:          y,
+			Baseline:   y + baseline,
+			Width:      width,
+			Offset:     dx,
+		})
 		y += lineH * state.lineHeight
 	}
+	return lines, truncated
+}
+
+// ellipsizeRow returns the row with an ellipsis at its end, removing characters and the trailing white space
+// before it until it fits in breakRowWidth. Only the ellipsis is left if nothing else fits. The font must be
+// set up by TextBreakLinesRune().
+func (ctx *Context) ellipsizeRow(row TextRow, breakRowWidth float32) TextRow {
+	text := row.Runes[row.StartIndex:row.EndIndex]
+	for {
+		for len(text) > 0 && unicode.IsSpace(text[len(text)-1]) {
+			text = text[:len(text)-1]
+		}
+		runes := append(append(make([]rune, 0, len(text)+1), text...), '…')
+		ellipsized := TextRow{Runes: runes, EndIndex: len(runes)}
+		if len(text) == 0 || ctx.textRowWidth(ellipsized) <= breakRowWidth {
+			return ellipsized
+		}
+		text = text[:len(text)-1]
+	}
+}
+
+// textRowWidth returns the advance of the runes of the row as TextRune() draws them, which is used to align
+// the row. It can differ a bit from the logical width of the row, which is measured in the whole text and
+// includes the kerning with the previous row. The font must be set up by TextBreakLinesRune().
+func (ctx *Context) textRowWidth(row TextRow) float32 {
+	state := ctx.getState()
+	width, _ := ctx.fs.TextBoundsOfRunes(0, 0, row.Runes[row.StartIndex:row.EndIndex])
+	return width / (state.getFontScale() * ctx.devicePxRatio)
+}
+
+// textBoxAlignOffset returns how much the first row of the text box is moved up, so that the whole block of
+// rows is aligned to y like a single row is by the vertical align.
+func textBoxAlignOffset(vAlign Align, rows int, lineStep float32) float32 {
+	if rows < 2 {
+		return 0
+	}
+	if vAlign&AlignMiddle != 0 {
+		return float32(rows-1) * lineStep * 0.5
+	} else if vAlign&AlignBottom != 0 {
+		return float32(rows-1) * lineStep
+	}
+	return 0
 }
 
 // TextBounds measures the specified text string. Parameter bounds should be a pointer to float[4],
@@ -992,11 +3093,14 @@ func (ctx *Context) TextBounds(x, y float32, str string) (float32, []float32) {
 
 	ctx.fs.SetSize(state.fontSize * scale)
 	ctx.fs.SetSpacing(state.letterSpacing * scale)
+	ctx.fs.SetTabStops(state.tabStops, scale)
 	ctx.fs.SetBlur(state.fontBlur * scale)
 	ctx.fs.SetAlign(fontstashmini.FONSAlign(state.textAlign))
 	ctx.fs.SetFont(state.fontID)
 
-	width, bounds := ctx.fs.TextBounds(x*scale, y*scale, str)
+	runes := []rune(str)
+	ctx.reportMissingGlyphs(runes)
+	width, bounds := ctx.fs.TextBoundsOfRunes(x*scale, y*scale, runes)
 	if bounds != nil {
 		bounds[1], bounds[3] = ctx.fs.LineBounds(y * scale)
 		bounds[0] *= invScale
@@ -1048,16 +3152,18 @@ func (ctx *Context) TextBoxBounds(x, y, breakRowWidth float32, str string) [4]fl
 	rMinY *= invScale
 	rMaxY *= invScale
 
-	for _, row := range ctx.TextBreakLinesRune(runes, breakRowWidth) {
+	rows := ctx.TextBreakLinesRune(runes, breakRowWidth)
+	y -= textBoxAlignOffset(vAlign, len(rows), lineH*state.lineHeight)
+	for _, row := range rows {
 		var dx float32
 		// Horizontal bounds
 		switch hAlign {
 		case AlignLeft:
 			dx = 0
 		case AlignCenter:
-			dx = breakRowWidth*0.5 - row.Width*0.5
+			dx = breakRowWidth*0.5 - ctx.textRowWidth(row)*0.5
 		case AlignRight:
-			dx = breakRowWidth - row.Width
+			dx = breakRowWidth - ctx.textRowWidth(row)
 		}
 		rMinX := x + row.MinX + dx
 		rMaxX := x + row.MaxX + dx
@@ -1074,6 +3180,29 @@ func (ctx *Context) TextBoxBounds(x, y, breakRowWidth float32, str string) [4]fl
 	return [4]float32{minX, minY, maxX, maxY}
 }
 
+// GlyphAdvances returns the advance widths of the runes of the text with the current font, size and
+// letter spacing in local coordinate space. Each advance includes the kerning and letter spacing to the
+// following rune, so the sum of the advances is the width of the text. Glyphs are not rendered to the font atlas.
+func (ctx *Context) GlyphAdvances(str string) []float32 {
+	state := ctx.getState()
+	scale := state.getFontScale() * ctx.devicePxRatio
+	invScale := 1.0 / scale
+	if state.fontID == fontstashmini.INVALID {
+		return nil
+	}
+
+	ctx.fs.SetSize(state.fontSize * scale)
+	ctx.fs.SetSpacing(state.letterSpacing * scale)
+	ctx.fs.SetTabStops(state.tabStops, scale)
+	ctx.fs.SetFont(state.fontID)
+
+	advances := ctx.fs.GlyphAdvances([]rune(str))
+	for i := range advances {
+		advances[i] *= invScale
+	}
+	return advances
+}
+
 // TextGlyphPositions calculates the glyph x positions of the specified text. If end is specified only the sub-string will be used.
 // Measured values are returned in local coordinate space.
 func (ctx *Context) TextGlyphPositions(x, y float32, str string) []GlyphPosition {
@@ -1091,6 +3220,7 @@ func (ctx *Context) TextGlyphPositionsRune(x, y float32, runes []rune) []GlyphPo
 
 	ctx.fs.SetSize(state.fontSize * scale)
 	ctx.fs.SetSpacing(state.letterSpacing * scale)
+	ctx.fs.SetTabStops(state.tabStops, scale)
 	ctx.fs.SetBlur(state.fontBlur * scale)
 	ctx.fs.SetAlign(fontstashmini.FONSAlign(state.textAlign))
 	ctx.fs.SetFont(state.fontID)
@@ -1121,6 +3251,35 @@ func (ctx *Context) TextGlyphPositionsRune(x, y float32, runes []rune) []GlyphPo
 	return positions
 }
 
+// FontStash returns the font stash used by the context, for advanced use which the Context API doesn't cover.
+// Text functions set font, size, spacing, blur and align of the stash from the current state on each call,
+// but other changes (e.g. resetting its atlas) may desync it from the font atlas images of the context.
+func (ctx *Context) FontStash() *fontstashmini.FontStash {
+	return ctx.fs
+}
+
+// GlyphBitmap rasterizes a single glyph with the current font face, size and blur into a freshly allocated
+// alpha buffer (one byte per pixel, w*h bytes), without touching the shared font atlas.
+// The glyph is rasterized in the same resolution as Text() renders it (font size scaled by the current
+// transform and device pixel ratio), and advance is returned in the same pixels.
+// ok is false if no valid font face is set.
+func (ctx *Context) GlyphBitmap(r rune) (pix []byte, w, h, advance int, ok bool) {
+	state := ctx.getState()
+	scale := state.getFontScale() * ctx.devicePxRatio
+	if state.fontID == fontstashmini.INVALID {
+		return nil, 0, 0, 0, false
+	}
+
+	ctx.fs.SetSize(state.fontSize * scale)
+	ctx.fs.SetSpacing(state.letterSpacing * scale)
+	ctx.fs.SetTabStops(state.tabStops, scale)
+	ctx.fs.SetBlur(state.fontBlur * scale)
+	ctx.fs.SetAlign(fontstashmini.FONSAlign(state.textAlign))
+	ctx.fs.SetFont(state.fontID)
+
+	return ctx.fs.GlyphBitmap(r)
+}
+
 // TextMetrics returns the vertical metrics based on the current text style.
 // Measured values are returned in local coordinate space.
 func (ctx *Context) TextMetrics() (float32, float32, float32) {
@@ -1133,6 +3292,7 @@ func (ctx *Context) TextMetrics() (float32, float32, float32) {
 
 	ctx.fs.SetSize(state.fontSize * scale)
 	ctx.fs.SetSpacing(state.letterSpacing * scale)
+	ctx.fs.SetTabStops(state.tabStops, scale)
 	ctx.fs.SetBlur(state.fontBlur * scale)
 	ctx.fs.SetAlign(fontstashmini.FONSAlign(state.textAlign))
 	ctx.fs.SetFont(state.fontID)
@@ -1141,6 +3301,22 @@ func (ctx *Context) TextMetrics() (float32, float32, float32) {
 	return ascender * invScale, descender * invScale, lineH * invScale
 }
 
+// FontVerticalMetricsExtended returns the vertical metrics of TextMetrics() with the line gap instead of the
+// line height, and the cap height and the x-height of the current font, which are useful to center icons
+// optically on the text. Cap height and x-height are positive distances above the baseline, read from the
+// OS/2 table of the font or measured on 'H' and 'x'. Measured values are returned in local coordinate space,
+// all are zero if no font is set.
+func (ctx *Context) FontVerticalMetricsExtended() (ascender, descender, lineGap, capHeight, xHeight float32) {
+	state := ctx.getState()
+	if state.fontID == fontstashmini.INVALID {
+		return 0, 0, 0, 0, 0
+	}
+	ascender, descender, lineH := ctx.TextMetrics()
+	capHeight, xHeight = ctx.fs.CapAndXHeight()
+	invScale := 1.0 / (state.getFontScale() * ctx.devicePxRatio)
+	return ascender, descender, lineH - (ascender - descender), capHeight * invScale, xHeight * invScale
+}
+
 // TextBreakLines breaks the specified text into lines. If end is specified only the sub-string will be used.
 // White space is stripped at the beginning of the rows, the text is split at word boundaries or when new-line characters are encountered.
 // Words longer than the max width are slit at nearest character (i.e. no hyphenation).
@@ -1162,6 +3338,7 @@ func (ctx *Context) TextBreakLinesRune(runes []rune, breakRowWidth float32) []Te
 
 	ctx.fs.SetSize(state.fontSize * scale)
 	ctx.fs.SetSpacing(state.letterSpacing * scale)
+	ctx.fs.SetTabStops(state.tabStops, scale)
 	ctx.fs.SetBlur(state.fontBlur * scale)
 	ctx.fs.SetAlign(fontstashmini.FONSAlign(state.textAlign))
 	ctx.fs.SetFont(state.fontID)
@@ -1196,19 +3373,22 @@ func (ctx *Context) TextBreakLinesRune(runes []rune, breakRowWidth float32) []Te
 			currentType = nvgSPACE
 		case 12: // \f
 			currentType = nvgSPACE
+		case 32: // space
+			currentType = nvgSPACE
 		case 0x00a0: // NBSP
 			currentType = nvgSPACE
 		case 10: // \n
+			// \r\n is a single new line
 			if prevCodePoint == 13 {
-				currentType = nvgNEWLINE
-			} else {
 				currentType = nvgSPACE
+			} else {
+				currentType = nvgNEWLINE
 			}
 		case 13: // \r
-			if prevCodePoint == 13 {
-				currentType = nvgNEWLINE
-			} else {
+			if prevCodePoint == 10 {
 				currentType = nvgSPACE
+			} else {
+				currentType = nvgNEWLINE
 			}
 		case 0x0085: // NEL
 			currentType = nvgNEWLINE
@@ -1248,6 +3428,7 @@ func (ctx *Context) TextBreakLinesRune(runes []rune, breakRowWidth float32) []Te
 				if currentType == nvgCHAR {
 					// The current char is the row so far
 					rowStartX = iter.X
+					iter.TabOriginX = rowStartX
 					rowStart = iter.CurrentIndex
 					rowEnd = iter.NextIndex
 					rowWidth = iter.NextX - rowStartX // q.x1 - rowStartX;
@@ -1263,12 +3444,6 @@ func (ctx *Context) TextBreakLinesRune(runes []rune, breakRowWidth float32) []Te
 				}
 			} else {
 				nextWidth := iter.NextX - rowStartX
-				// track last non-white space character
-				if currentType == nvgCHAR {
-					rowEnd = iter.NextIndex
-					rowWidth = iter.NextX - rowStartX
-					rowMaxX = quad.X1 - rowStartX
-				}
 				// track last end of a word
 				if prevType == nvgCHAR && currentType == nvgSPACE {
 					breakEnd = iter.CurrentIndex
@@ -1296,6 +3471,7 @@ func (ctx *Context) TextBreakLinesRune(runes []rune, breakRowWidth float32) []Te
 							NextIndex:  iter.CurrentIndex,
 						})
 						rowStartX = iter.X
+						iter.TabOriginX = rowStartX
 						rowStart = iter.CurrentIndex
 						rowEnd = iter.NextIndex
 						rowWidth = iter.NextX - rowStartX
@@ -1316,6 +3492,7 @@ func (ctx *Context) TextBreakLinesRune(runes []rune, breakRowWidth float32) []Te
 							NextIndex:  wordStart,
 						})
 						rowStartX = wordStartX
+						iter.TabOriginX = rowStartX
 						rowStart = wordStart
 						rowEnd = iter.NextIndex
 						rowWidth = iter.NextX - rowStartX
@@ -1327,6 +3504,12 @@ func (ctx *Context) TextBreakLinesRune(runes []rune, breakRowWidth float32) []Te
 					breakEnd = rowStart
 					breakWidth = 0.0
 					breakMaxX = 0.0
+				} else if currentType == nvgCHAR {
+					// track last non-white space character, after the break so that a row broken
+					// mid-word doesn't measure the character which starts the next row.
+					rowEnd = iter.NextIndex
+					rowWidth = iter.NextX - rowStartX
+					rowMaxX = quad.X1 - rowStartX
 				}
 			}
 		}
@@ -1349,26 +3532,40 @@ func (ctx *Context) TextBreakLinesRune(runes []rune, breakRowWidth float32) []Te
 }
 
 func createInternal(params nvgParams) (*Context, error) {
+	return createInternalWithOptions(params, ContextOptions{})
+}
+
+func createInternalWithOptions(params nvgParams, options ContextOptions) (*Context, error) {
+	atlasSize, atlasMax, err := options.fontAtlasSizes()
+	if err != nil {
+		return nil, err
+	}
 	context := &Context{
-		params:     params,
-		states:     make([]nvgState, 0, nvgMaxStates),
-		fontImages: make([]int, nvgMaxFontImages),
-		commands:   make([]float32, 0, nvgInitCommandsSize),
+		params:      params,
+		autoWinding: true,
+		dpi:         nvgDefaultDPI,
+		states:      make([]nvgState, 0, nvgMaxStates),
+		fontImages:  make([]int, nvgMaxFontImages),
+		commands:    make([]float32, 0, nvgInitCommandsSize),
 		cache: nvgPathCache{
 			points:   make([]nvgPoint, 0, nvgInitPointsSize),
 			paths:    make([]nvgPath, 0, nvgInitPathsSize),
 			vertexes: make([]nvgVertex, 0, nvgInitVertsSize),
+			maxDepth: nvgTessDepth,
 		},
 	}
+	context.lastPointIdx = -1
+	context.moveToIdx = -1
 	context.Save()
 	context.Reset()
 	context.setDevicePixelRatio(1.0)
 	context.params.renderCreate()
 
-	context.fs = fontstashmini.New(nvgInitFontImageSize, nvgInitFontImageSize)
+	context.fs = fontstashmini.New(atlasSize, atlasSize)
 
-	context.fontImages[0] = context.params.renderCreateTexture(nvgTextureALPHA, nvgInitFontImageSize, nvgInitFontImageSize, 0, nil)
+	context.fontImages[0] = context.params.renderCreateTexture(nvgTextureALPHA, atlasSize, atlasSize, 0, nil)
 	context.fontImageIdx = 0
+	context.fontImageMax = atlasMax
 
 	return context, nil
 }
@@ -1381,24 +3578,44 @@ func (ctx *Context) setDevicePixelRatio(ratio float32) {
 }
 
 func (ctx *Context) getState() *nvgState {
+	ctx.checkConcurrency()
 	return &ctx.states[len(ctx.states)-1]
 }
 
+func (ctx *Context) checkConcurrency() {
+	if !ctx.checkOwner {
+		return
+	}
+	if id := goroutineID(); id != ctx.ownerID {
+		panic(fmt.Sprintf("nanovgo: context owned by goroutine %d is used from goroutine %d", ctx.ownerID, id))
+	}
+}
+
+// appendCommand transforms the commands and appends them to the current path. Commands with NaN or infinite
+// coordinates, before or after the transform, are dropped as a whole, so the rest of the path is still drawn.
 func (ctx *Context) appendCommand(vals []float32) {
 	xForm := ctx.getState().xform
+	for _, v := range vals {
+		if !isFiniteF(v) {
+			return
+		}
+	}
 
-	if nvgCommands(vals[0]) != nvgCLOSE && nvgCommands(vals[0]) != nvgWINDING {
-		ctx.commandX = vals[len(vals)-2]
-		ctx.commandY = vals[len(vals)-1]
+	// The current point is kept in user space.
+	setCommandXY := nvgCommands(vals[0]) != nvgCLOSE && nvgCommands(vals[0]) != nvgWINDING
+	var commandX, commandY float32
+	if setCommandXY {
+		commandX, commandY = vals[len(vals)-2], vals[len(vals)-1]
 	}
 
+	base := len(ctx.commands)
+	ctx.commands = append(ctx.commands, vals...)
+	vals = ctx.commands[base:]
+
 	i := 0
 	for i < len(vals) {
 		switch nvgCommands(vals[i]) {
-		case nvgMOVETO:
-			vals[i+1], vals[i+2] = xForm.TransformPoint(vals[i+1], vals[i+2])
-			i += 3
-		case nvgLINETO:
+		case nvgMOVETO, nvgLINETO:
 			vals[i+1], vals[i+2] = xForm.TransformPoint(vals[i+1], vals[i+2])
 			i += 3
 		case nvgBEZIERTO:
@@ -1406,7 +3623,51 @@ func (ctx *Context) appendCommand(vals []float32) {
 			vals[i+3], vals[i+4] = xForm.TransformPoint(vals[i+3], vals[i+4])
 			vals[i+5], vals[i+6] = xForm.TransformPoint(vals[i+5], vals[i+6])
 			i += 7
+		case nvgQUADTO:
+			vals[i+1], vals[i+2] = xForm.TransformPoint(vals[i+1], vals[i+2])
+			vals[i+3], vals[i+4] = xForm.TransformPoint(vals[i+3], vals[i+4])
+			i += 5
+		case nvgWINDING:
+			i += 2
+		default:
+			i++
+		}
+	}
+	// Check before snapping, which changes the previous point of the path too.
+	for _, v := range vals {
+		if !isFiniteF(v) {
+			ctx.commands = ctx.commands[:base]
+			return
+		}
+	}
+
+	if setCommandXY {
+		ctx.commandX, ctx.commandY = commandX, commandY
+	}
+	i = 0
+	for i < len(vals) {
+		switch nvgCommands(vals[i]) {
+		case nvgMOVETO:
+			ctx.lastPointIdx = base + i + 1
+			ctx.moveToIdx = ctx.lastPointIdx
+			i += 3
+		case nvgLINETO:
+			if ctx.snapToPixel && ctx.lastPointIdx >= 0 {
+				ctx.snapSegment(ctx.lastPointIdx, base+i+1)
+			}
+			ctx.lastPointIdx = base + i + 1
+			i += 3
+		case nvgBEZIERTO:
+			ctx.lastPointIdx = base + i + 5
+			i += 7
+		case nvgQUADTO:
+			ctx.lastPointIdx = base + i + 3
+			i += 5
 		case nvgCLOSE:
+			// The closing segment goes back to the move to, e.g. the top edge of Rect().
+			if ctx.snapToPixel && ctx.lastPointIdx >= 0 && ctx.moveToIdx >= 0 && ctx.moveToIdx != ctx.lastPointIdx {
+				ctx.snapSegment(ctx.lastPointIdx, ctx.moveToIdx)
+			}
 			i++
 		case nvgWINDING:
 			i += 2
@@ -1414,7 +3675,22 @@ func (ctx *Context) appendCommand(vals []float32) {
 			i++
 		}
 	}
-	ctx.commands = append(ctx.commands, vals...)
+}
+
+// snapSegment snaps the shared coordinate of axis aligned line segment to the nearest device pixel center.
+// p0 and p1 are indexes of the x-coordinates of the segment end points in the command buffer.
+func (ctx *Context) snapSegment(p0, p1 int) {
+	const tol = 1e-4
+	cmds := ctx.commands
+	if absF(cmds[p0+1]-cmds[p1+1]) < tol {
+		y := snapHalfPixel(cmds[p1+1], ctx.devicePxRatio)
+		cmds[p0+1] = y
+		cmds[p1+1] = y
+	} else if absF(cmds[p0]-cmds[p1]) < tol {
+		x := snapHalfPixel(cmds[p1], ctx.devicePxRatio)
+		cmds[p0] = x
+		cmds[p1] = x
+	}
 }
 
 func (ctx *Context) flattenPaths() {
@@ -1443,6 +3719,15 @@ func (ctx *Context) flattenPaths() {
 					ctx.commands[i+5], ctx.commands[i+6], 0, nvgPtCORNER, ctx.tessTol, ctx.distTol)
 			}
 			i += 7
+		case nvgQUADTO:
+			last := cache.lastPoint()
+			if last != nil {
+				cache.tesselateQuad(
+					last.x, last.y,
+					ctx.commands[i+1], ctx.commands[i+2],
+					ctx.commands[i+3], ctx.commands[i+4], 0, nvgPtCORNER, ctx.tessTol, ctx.distTol)
+			}
+			i += 5
 		case nvgCLOSE:
 			cache.closePath()
 			i++
@@ -1470,7 +3755,7 @@ func (ctx *Context) flattenPaths() {
 		}
 
 		// Enforce winding.
-		if path.count > 2 {
+		if path.count > 2 && (ctx.autoWinding || path.explicitWinding) {
 			area := polyArea(points, path.count)
 			if path.winding == Solid && area < 0.0 {
 				polyReverse(points, path.count)
@@ -1514,9 +3799,30 @@ func (ctx *Context) flushTextTexture() {
 	}
 }
 
+// FontAtlasUsage returns how full the font atlas is. pages is the number of font atlas textures in use,
+// usedBytes and totalBytes are summed over these pages. Pages before the current one are counted as full.
+func (ctx *Context) FontAtlasUsage() (pages int, usedBytes, totalBytes int) {
+	for i := 0; i < ctx.fontImageIdx; i++ {
+		w, h, _ := ctx.ImageSize(ctx.fontImages[i])
+		usedBytes += w * h
+		totalBytes += w * h
+	}
+	used, total := ctx.fs.AtlasUsage()
+	return ctx.fontImageIdx + 1, usedBytes + used, totalBytes + total
+}
+
+// SetAtlasOverflowHandler sets the function called when the font atlas runs out of space and
+// no more atlas pages can be allocated. Glyphs which don't fit are not rendered.
+func (ctx *Context) SetAtlasOverflowHandler(handler func()) {
+	ctx.atlasOverflow = handler
+}
+
 func (ctx *Context) allocTextAtlas() bool {
 	ctx.flushTextTexture()
 	if ctx.fontImageIdx >= nvgMaxFontImages-1 {
+		if ctx.atlasOverflow != nil {
+			ctx.atlasOverflow()
+		}
 		return false
 	}
 	var iw, ih int
@@ -1530,9 +3836,9 @@ func (ctx *Context) allocTextAtlas() bool {
 		} else {
 			iw *= 2
 		}
-		if iw > nvgMaxFontImageSize || ih > nvgMaxFontImageSize {
-			iw = nvgMaxFontImageSize
-			ih = nvgMaxFontImageSize
+		if iw > ctx.fontImageMax || ih > ctx.fontImageMax {
+			iw = ctx.fontImageMax
+			ih = ctx.fontImageMax
 		}
 		ctx.fontImages[ctx.fontImageIdx+1] = ctx.params.renderCreateTexture(nvgTextureALPHA, iw, ih, 0, nil)
 	}
@@ -1544,15 +3850,26 @@ func (ctx *Context) allocTextAtlas() bool {
 func (ctx *Context) renderText(vertexes []nvgVertex) {
 	state := ctx.getState()
 	paint := state.fill
+	fontImage := ctx.fontImages[ctx.fontImageIdx]
 
-	// Render triangles
-	paint.image = ctx.fontImages[ctx.fontImageIdx]
-
-	// Apply global alpha
+	// Apply global alpha, to the copy so that it doesn't compound over draws like in Fill() and Stroke().
 	paint.innerColor.A *= state.alpha
 	paint.outerColor.A *= state.alpha
 
-	ctx.params.renderTriangleStrip(&paint, &state.scissor, vertexes)
+	// Render triangles
+	if painter, ok := ctx.params.(nvgTextPainter); ok && (paint.image != 0 || paint.innerColor != paint.outerColor) {
+		if ctx.inLayer {
+			paint, scissor, fontImage, vertexes := paint, state.scissor, fontImage, append([]nvgVertex(nil), vertexes...)
+			ctx.recordLayerCall(func(params nvgParams) {
+				params.(nvgTextPainter).renderTextPaint(&paint, &scissor, fontImage, vertexes)
+			})
+		} else {
+			painter.renderTextPaint(&paint, &state.scissor, fontImage, vertexes)
+		}
+	} else {
+		paint.image = fontImage
+		ctx.renderTriangleStrip(&paint, &state.scissor, vertexes)
+	}
 
 	ctx.drawCallCount++
 	ctx.textTriCount += len(vertexes) / 3
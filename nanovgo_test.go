@@ -1,6 +1,13 @@
 package nanovgo
 
 import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
 	"testing"
 )
 
@@ -71,3 +78,1608 @@ func TestStateSaveRestore2(t *testing.T) {
 		t.Errorf("Restore() should set saved xform, but %v", topStateAgain.xform)
 	}
 }
+
+// testParams is a nvgParams which doesn't render anything but records submitted geometry.
+type testParams struct {
+	textureID int
+	textures  map[int][3]int
+	data      map[int][]byte
+	fills     [][]nvgVertex
+	strokes   [][]nvgVertex
+	triangles [][]nvgVertex
+}
+
+func (p *testParams) edgeAntiAlias() bool { return false }
+func (p *testParams) renderCreate() error { return nil }
+func (p *testParams) renderCreateTexture(texType nvgTextureType, w, h int, flags ImageFlags, data []byte) int {
+	if p.textures == nil {
+		p.textures = make(map[int][3]int)
+	}
+	if p.data == nil {
+		p.data = make(map[int][]byte)
+	}
+	p.textureID++
+	p.textures[p.textureID] = [3]int{w, h, int(texType)}
+	p.data[p.textureID] = append([]byte(nil), data...)
+	return p.textureID
+}
+func (p *testParams) renderDeleteTexture(image int) error {
+	delete(p.textures, image)
+	return nil
+}
+func (p *testParams) renderUpdateTexture(image, x, y, w, h int, data []byte) error { return nil }
+func (p *testParams) renderGetTextureSize(image int) (int, int, error) {
+	tex := p.textures[image]
+	return tex[0], tex[1], nil
+}
+func (p *testParams) renderViewport(width, height int) {}
+func (p *testParams) renderCancel()                    {}
+func (p *testParams) renderFlush()                     {}
+func (p *testParams) renderFill(paint *Paint, scissor *nvgScissor, fringe float32, bounds [4]float32, paths []nvgPath) {
+	for i := range paths {
+		p.fills = append(p.fills, append([]nvgVertex(nil), paths[i].fills...))
+	}
+}
+func (p *testParams) renderStroke(paint *Paint, scissor *nvgScissor, fringe float32, strokeWidth float32, paths []nvgPath) {
+	for i := range paths {
+		p.strokes = append(p.strokes, append([]nvgVertex(nil), paths[i].strokes...))
+	}
+}
+func (p *testParams) renderTriangles(paint *Paint, scissor *nvgScissor, vertexes []nvgVertex) {
+	p.triangles = append(p.triangles, append([]nvgVertex(nil), vertexes...))
+}
+func (p *testParams) renderTriangleStrip(paint *Paint, scissor *nvgScissor, vertexes []nvgVertex) {
+	p.triangles = append(p.triangles, append([]nvgVertex(nil), vertexes...))
+}
+func (p *testParams) renderDelete()                    {}
+func (p *testParams) renderCapabilities() Capabilities { return Capabilities{} }
+
+func newTestContext(t *testing.T) (*Context, *testParams) {
+	params := &testParams{}
+	ctx, err := createInternal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.BeginFrame(800, 600, 1.0)
+	return ctx, params
+}
+
+func TestAutoWinding(t *testing.T) {
+	// Self-intersecting path which larger lobe has clockwise order.
+	drawBowTie := func(ctx *Context) {
+		ctx.BeginPath()
+		ctx.MoveTo(200, 300)
+		ctx.LineTo(0, 300)
+		ctx.LineTo(100, 0)
+		ctx.LineTo(0, 0)
+		ctx.ClosePath()
+		ctx.Fill()
+	}
+
+	ctx, params := newTestContext(t)
+	drawBowTie(ctx)
+	if first := params.fills[0][0]; first.x != 0 || first.y != 0 {
+		t.Errorf("auto winding should reverse path, but first point is (%f, %f)", first.x, first.y)
+	}
+
+	ctx, params = newTestContext(t)
+	ctx.SetAutoWinding(false)
+	drawBowTie(ctx)
+	if first := params.fills[0][0]; first.x != 200 || first.y != 300 {
+		t.Errorf("authored point order should be kept, but first point is (%f, %f)", first.x, first.y)
+	}
+}
+
+func loadTestFont(t *testing.T, ctx *Context) {
+	font := ctx.CreateFont("sans", "sample/Roboto-Regular.ttf")
+	if font == -1 {
+		t.Fatal("can't load sample/Roboto-Regular.ttf")
+	}
+	ctx.SetFontFaceID(font)
+	ctx.SetFontSize(20)
+}
+
+func TestTextBreakLinesNewLine(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	loadTestFont(t, ctx)
+
+	for _, text := range []string{"a\nb", "a\r\nb", "a\rb"} {
+		rows := ctx.TextBreakLines(text, 1000)
+		if len(rows) != 2 {
+			t.Errorf("%q should be broken into 2 rows, but %d rows", text, len(rows))
+			continue
+		}
+		if first := string(rows[0].Runes[rows[0].StartIndex:rows[0].EndIndex]); first != "a" {
+			t.Errorf("first row of %q should be \"a\", but %q", text, first)
+		}
+		if second := string(rows[1].Runes[rows[1].StartIndex:rows[1].EndIndex]); second != "b" {
+			t.Errorf("second row of %q should be \"b\", but %q", text, second)
+		}
+	}
+}
+
+func TestArcSegments(t *testing.T) {
+	arc := func(r float32) (int, float32) {
+		ctx, _ := newTestContext(t)
+		ctx.BeginPath()
+		ctx.Arc(0, 0, r, 0, PI*2, Clockwise)
+		// Count bezier segments and measure radial error at their middle.
+		var segments int
+		var maxErr float32
+		cmds := ctx.commands
+		x0, y0 := cmds[1], cmds[2]
+		for i := 3; i < len(cmds); i += 7 {
+			segments++
+			x := 0.125*x0 + 0.375*cmds[i+1] + 0.375*cmds[i+3] + 0.125*cmds[i+5]
+			y := 0.125*y0 + 0.375*cmds[i+2] + 0.375*cmds[i+4] + 0.125*cmds[i+6]
+			maxErr = maxF(maxErr, absF(sqrtF(x*x+y*y)-r))
+			x0, y0 = cmds[i+5], cmds[i+6]
+		}
+		return segments, maxErr
+	}
+	small, _ := arc(10)
+	large, largeErr := arc(1000)
+	if small != 4 {
+		t.Errorf("small circle should have 4 segments, but %d", small)
+	}
+	if large <= small {
+		t.Errorf("large circle should have more segments than small one, but %d <= %d", large, small)
+	}
+	if largeErr > 0.25 {
+		t.Errorf("large circle radial error should be less than tessellation tolerance, but %f", largeErr)
+	}
+}
+
+func TestArcLargeAngles(t *testing.T) {
+	tests := []struct {
+		a0, a1 float32
+		dir    Direction
+	}{
+		{100 * PI, 102 * PI, Clockwise},
+		{100 * PI, 98 * PI, CounterClockwise},
+		{-100 * PI, -98 * PI, Clockwise},
+	}
+	for _, test := range tests {
+		ctx, _ := newTestContext(t)
+		ctx.BeginPath()
+		ctx.Arc(0, 0, 10, test.a0, test.a1, test.dir)
+		cmds := ctx.commands
+		if segments := (len(cmds) - 3) / 7; segments != 4 {
+			t.Errorf("arc %g-%g should be full circle of 4 segments, but %d", test.a0, test.a1, segments)
+		}
+		x0, y0 := cmds[1], cmds[2]
+		x1, y1 := cmds[len(cmds)-2], cmds[len(cmds)-1]
+		if absF(x1-x0) > 1e-4 || absF(y1-y0) > 1e-4 {
+			t.Errorf("arc %g-%g should be closed, but starts at (%g, %g) and ends at (%g, %g)", test.a0, test.a1, x0, y0, x1, y1)
+		}
+		for i := 3; i < len(cmds); i += 7 {
+			x, y := cmds[i+5], cmds[i+6]
+			if r := sqrtF(x*x + y*y); absF(r-10) > 1e-4 {
+				t.Errorf("arc %g-%g point (%g, %g) should be on the circle, but radius is %g", test.a0, test.a1, x, y, r)
+			}
+		}
+	}
+
+	if a := NormalizeAngle(100 * PI); absF(a) > 1e-4 {
+		t.Errorf("NormalizeAngle(100*PI) should be 0, but %g", a)
+	}
+	if a := NormalizeAngle(PI * 1.5); absF(a+PI*0.5) > 1e-6 {
+		t.Errorf("NormalizeAngle(1.5*PI) should be -0.5*PI, but %g", a)
+	}
+}
+
+func TestMultiplyGlobalAlpha(t *testing.T) {
+	c := Context{}
+	c.Save()
+	c.Reset()
+
+	c.Save()
+	c.MultiplyGlobalAlpha(0.5)
+	c.Save()
+	c.MultiplyGlobalAlpha(0.5)
+	if alpha := c.GlobalAlpha(); alpha != 0.25 {
+		t.Errorf("nested MultiplyGlobalAlpha(0.5) should result in 0.25, but %f", alpha)
+	}
+	c.Restore()
+	if alpha := c.GlobalAlpha(); alpha != 0.5 {
+		t.Errorf("Restore() should set parent's alpha 0.5, but %f", alpha)
+	}
+	c.Restore()
+	if alpha := c.GlobalAlpha(); alpha != 1.0 {
+		t.Errorf("Restore() should set initial alpha 1.0, but %f", alpha)
+	}
+}
+
+func vertexBounds(vertexes [][]nvgVertex) [4]float32 {
+	bounds := [4]float32{1e6, 1e6, -1e6, -1e6}
+	for _, path := range vertexes {
+		for _, v := range path {
+			bounds = [4]float32{minF(bounds[0], v.x), minF(bounds[1], v.y), maxF(bounds[2], v.x), maxF(bounds[3], v.y)}
+		}
+	}
+	return bounds
+}
+
+func TestStrokeAlign(t *testing.T) {
+	tests := []struct {
+		align  StrokeAlign
+		bounds [4]float32
+	}{
+		{StrokeCenter, [4]float32{8, 8, 112, 112}},
+		{StrokeInner, [4]float32{10, 10, 110, 110}},
+		{StrokeOuter, [4]float32{6, 6, 114, 114}},
+	}
+	for _, test := range tests {
+		ctx, params := newTestContext(t)
+		ctx.SetStrokeWidth(4)
+		ctx.SetStrokeAlign(test.align)
+		ctx.BeginPath()
+		ctx.Rect(10, 10, 100, 100)
+		ctx.Stroke()
+
+		bounds := vertexBounds(params.strokes)
+		for i := range bounds {
+			if absF(bounds[i]-test.bounds[i]) > 1e-3 {
+				t.Errorf("stroke align %d: bounds should be %v, but %v", test.align, test.bounds, bounds)
+				break
+			}
+		}
+	}
+}
+
+func TestTextPixelSnap(t *testing.T) {
+	firstGlyphX := func(snap bool, x float32) float32 {
+		ctx, params := newTestContext(t)
+		loadTestFont(t, ctx)
+		ctx.SetTextPixelSnap(snap)
+		ctx.Text(x, 50, "A")
+		if len(params.triangles) == 0 || len(params.triangles[0]) == 0 {
+			t.Fatal("no glyph is rendered")
+		}
+		return params.triangles[0][1].x
+	}
+	if x0, x1 := firstGlyphX(true, 10.0), firstGlyphX(true, 10.3); x0 != x1 {
+		t.Errorf("snapped glyph positions should be same, but %f and %f", x0, x1)
+	}
+	if x0, x1 := firstGlyphX(false, 10.0), firstGlyphX(false, 10.3); absF(x1-x0-0.3) > 1e-3 {
+		t.Errorf("glyph positions should differ by 0.3 without snapping, but %f and %f", x0, x1)
+	}
+}
+
+func TestCreateImageFromGoImageAlpha(t *testing.T) {
+	ctx, params := newTestContext(t)
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	img.Pix = []byte{
+		255, 0, 0, 255, 0, 255, 0, 128,
+		0, 0, 255, 64, 200, 100, 50, 0,
+	}
+	handle := ctx.CreateImageFromGoImage(0, img)
+	if !bytes.Equal(params.data[handle], img.Pix) {
+		t.Errorf("NRGBA pixels should be uploaded as is, but %v", params.data[handle])
+	}
+
+	// Premultiplied RGBA should be converted to straight alpha, and sub-image offset should be honored.
+	rgba := image.NewRGBA(image.Rect(0, 0, 3, 3))
+	rgba.Set(1, 1, color.NRGBA{R: 200, G: 100, B: 50, A: 128})
+	handle = ctx.CreateImageFromGoImage(0, rgba.SubImage(image.Rect(1, 1, 2, 2)))
+	pix := params.data[handle]
+	if len(pix) != 4 || pix[3] != 128 || absF(float32(pix[0])-200) > 2 || absF(float32(pix[1])-100) > 2 || absF(float32(pix[2])-50) > 2 {
+		t.Errorf("RGBA pixel should be converted to straight alpha {200 100 50 128}, but %v", pix)
+	}
+
+	// With ImagePreMultiplied, premultiplied pixels are uploaded, converted from NRGBA too.
+	handle = ctx.CreateImageFromGoImage(ImagePreMultiplied, rgba)
+	if !bytes.Equal(params.data[handle], rgba.Pix) {
+		t.Errorf("premultiplied RGBA pixels should be uploaded as is, but %v", params.data[handle])
+	}
+	handle = ctx.CreateImageFromGoImage(ImagePreMultiplied, img)
+	want := []byte{
+		255, 0, 0, 255, 0, 128, 0, 128,
+		0, 0, 64, 64, 0, 0, 0, 0,
+	}
+	if pix := params.data[handle]; len(pix) != len(want) {
+		t.Errorf("NRGBA pixels should be premultiplied to %v, but %v", want, pix)
+	} else {
+		for i := range want {
+			if absF(float32(pix[i])-float32(want[i])) > 1 {
+				t.Errorf("NRGBA pixels should be premultiplied to %v, but %v", want, pix)
+				break
+			}
+		}
+	}
+}
+
+func TestImagePatternFlipped(t *testing.T) {
+	sampleUV := func(paint Paint, x, y float32) (float32, float32) {
+		px, py := paint.Transform().Inverse().TransformPoint(x, y)
+		return px / paint.Extent()[0], py / paint.Extent()[1]
+	}
+	normal := ImagePattern(10, 20, 100, 50, 0, 1, 1)
+	flipped := ImagePatternFlipped(10, 20, 100, 50, 0, 1, 1, true, false)
+
+	u, v := sampleUV(normal, 35, 30)
+	if absF(u-0.25) > 1e-5 || absF(v-0.2) > 1e-5 {
+		t.Errorf("normal pattern should sample (0.25, 0.2), but (%f, %f)", u, v)
+	}
+	u, v = sampleUV(flipped, 35, 30)
+	if absF(u-0.75) > 1e-5 || absF(v-0.2) > 1e-5 {
+		t.Errorf("flipX pattern should sample (0.75, 0.2), but (%f, %f)", u, v)
+	}
+}
+
+// scissorMaskAt evaluates scissorMask() of the GL fragment shader with the uniforms.
+func scissorMaskAt(frag *glFragUniforms, x, y float32) float32 {
+	sx := absF(frag[0]*x+frag[4]*y+frag[8]) - frag[32]
+	sy := absF(frag[1]*x+frag[5]*y+frag[9]) - frag[33]
+	return clampF(0.5-sx*frag[34], 0, 1) * clampF(0.5-sy*frag[35], 0, 1)
+}
+
+func TestRotatedScissor(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	ctx.Translate(400, 300)
+	ctx.Rotate(DegToRad(30))
+	ctx.Scissor(-50, -25, 100, 50)
+
+	var frag glFragUniforms
+	paint := ctx.getState().fill
+	c := &glContext{}
+	if err := c.convertPaint(&frag, &paint, &ctx.getState().scissor, 1, 1, -1); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		x, y   float32 // in the scissor space
+		inside bool
+	}{
+		{0, 0, true},
+		{45, 20, true},
+		{-45, -20, true},
+		{55, 0, false},
+		{0, 30, false},
+	}
+	xform := ctx.getState().xform
+	for _, test := range tests {
+		x, y := xform.TransformPoint(test.x, test.y)
+		mask := scissorMaskAt(&frag, x, y)
+		if test.inside && mask != 1 || !test.inside && mask != 0 {
+			t.Errorf("scissor mask at (%g, %g) should be inside=%v, but %f", test.x, test.y, test.inside, mask)
+		}
+	}
+
+	// Corner of the axis aligned bounding box is outside of the rotated scissor.
+	bounds := transformRectBounds(xform, -50, -25, 100, 50)
+	if mask := scissorMaskAt(&frag, bounds[0]+1, bounds[1]+1); mask != 0 {
+		t.Errorf("scissor should be rotated rectangle, but its bounding box corner has mask %f", mask)
+	}
+}
+
+func TestMaxTessellationDepth(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	// Keep the points in drawing order.
+	ctx.SetAutoWinding(false)
+	for _, depth := range []int{10, 4, 0} {
+		ctx.SetMaxTessellationDepth(depth)
+		ctx.BeginPath()
+		ctx.MoveTo(0, 0)
+		// Cusp with huge control points never becomes flat enough.
+		ctx.BezierTo(1e7, 1e7, -1e7, 1e7, 1, 0)
+		ctx.flattenPaths()
+
+		points := ctx.cache.points
+		if max := 1 + 1<<uint(depth+1); len(points) > max {
+			t.Errorf("depth %d: curve should have at most %d points, but %d", depth, max, len(points))
+		}
+		last := points[len(points)-1]
+		if last.x != 1 || last.y != 0 {
+			t.Errorf("depth %d: curve should end at (1, 0), but (%g, %g)", depth, last.x, last.y)
+		}
+	}
+}
+
+func TestDashedRoundedRect(t *testing.T) {
+	ctx, p := newTestContext(t)
+	const dash, gap, radius = 10, 5, 30
+	ctx.BeginPath()
+	ctx.RoundedRect(10, 10, 200, 100, radius)
+	ctx.flattenPaths()
+	ctx.cache.dashPaths([]float32{dash, gap}, 0, ctx.distTol)
+
+	perimeter := 2*(200-2*radius) + 2*(100-2*radius) + 2*PI*radius
+	pieces := ctx.cache.paths
+	if n := int(ceilF(perimeter / (dash + gap))); len(pieces) < n-1 || len(pieces) > n {
+		t.Fatalf("rounded rect of perimeter %g should have about %d dashes, but %d", perimeter, n, len(pieces))
+	}
+	var curved int
+	for i, piece := range pieces {
+		points := ctx.cache.points[piece.first : piece.first+piece.count]
+		var length float32
+		for j := 1; j < len(points); j++ {
+			dx, dy := points[j].x-points[j-1].x, points[j].y-points[j-1].y
+			length += sqrtF(dx*dx + dy*dy)
+		}
+		if len(points) > 2 {
+			curved++
+		}
+		// The dash across the start of the closed path is joined with the last one.
+		if i > 0 && absF(length-dash) > 0.01 {
+			t.Errorf("dash %d should be %g long, but %g", i, float32(dash), length)
+		}
+		if i > 0 {
+			prev := pieces[i-1]
+			end := ctx.cache.points[prev.first+prev.count-1]
+			dx, dy := points[0].x-end.x, points[0].y-end.y
+			if d := sqrtF(dx*dx + dy*dy); d > gap+0.01 || d < gap*0.9 {
+				t.Errorf("gap before dash %d should be %g long, but %g", i, float32(gap), d)
+			}
+		}
+	}
+	if curved < 4 {
+		t.Errorf("dashes should follow the four corners, but only %d dashes are curved", curved)
+	}
+
+	// Stroke() dashes the path and leaves the cache for the next fill.
+	n := len(pieces)
+	ctx.cache.clearPathCache()
+	ctx.SetLineDash([]float32{dash, gap})
+	ctx.Stroke()
+	if len(p.strokes) != n {
+		t.Errorf("dashed stroke should render %d paths, but %d", n, len(p.strokes))
+	}
+	ctx.flattenPaths()
+	if len(ctx.cache.paths) != 1 {
+		t.Errorf("path should be flattened again after dashed stroke, but has %d paths", len(ctx.cache.paths))
+	}
+}
+
+func TestDashTinyOnLongPath(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	ctx.BeginPath()
+	ctx.MoveTo(0, 0)
+	ctx.LineTo(1e6, 0)
+	ctx.flattenPaths()
+	// The dashes are below the float32 precision at the end of the line, so it is kept solid.
+	ctx.cache.dashPaths([]float32{1e-3, 1e-3}, 0, ctx.distTol)
+
+	if len(ctx.cache.paths) != 1 || ctx.cache.paths[0].count != 2 {
+		t.Fatalf("line with too many dashes should be kept solid, but %d paths", len(ctx.cache.paths))
+	}
+	if end := ctx.cache.points[1]; end.x != 1e6 || end.y != 0 {
+		t.Errorf("solid line should end at (1e6, 0), but (%g, %g)", end.x, end.y)
+	}
+}
+
+func TestLineDashSpeed(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	ctx.SetLineDash([]float32{10, 10})
+	ctx.SetLineDashSpeed(4)
+	firstDash := func() (float32, float32) {
+		ctx.BeginPath()
+		ctx.MoveTo(0, 0)
+		ctx.LineTo(100, 0)
+		ctx.flattenPaths()
+		state := ctx.getState()
+		ctx.dashStroke(state, state.strokeWidth, 1)
+		path := ctx.cache.paths[0]
+		return ctx.cache.points[path.first].x, ctx.cache.points[path.first+path.count-1].x
+	}
+	if start, end := firstDash(); start != 0 || end != 10 {
+		t.Errorf("dash should not move at time 0, but %g-%g", start, end)
+	}
+	// After 1.5 seconds, dashes and gaps have moved 6 units forward.
+	ctx.SetFrameTime(1.5)
+	if start, end := firstDash(); absF(start-6) > 1e-3 || absF(end-16) > 1e-3 {
+		t.Errorf("first dash should be 6-16, but %g-%g", start, end)
+	}
+	ctx.SetLineDashOffset(6)
+	if start, end := firstDash(); start != 0 || absF(end-10) > 1e-3 {
+		t.Errorf("dash offset should be added to the motion, but first dash %g-%g", start, end)
+	}
+}
+
+func TestTextBoxVerticalAlign(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	loadTestFont(t, ctx)
+	ascender, descender, lineH := ctx.TextMetrics()
+	const y = 100
+
+	tests := []struct {
+		align    Align
+		baseline float32 // of the first row
+	}{
+		{AlignBaseline, y},
+		{AlignTop, y + ascender},
+		{AlignMiddle, y - lineH + (ascender+descender)*0.5},
+		{AlignBottom, y - lineH*2 + descender},
+	}
+	for _, test := range tests {
+		ctx.SetTextAlign(AlignLeft | test.align)
+		lines := ctx.TextBoxLines(10, y, 200, "one\ntwo\nthree")
+		if len(lines) != 3 {
+			t.Fatalf("text box should have 3 rows, but %d", len(lines))
+		}
+		if absF(lines[0].Baseline-test.baseline) > 0.01 {
+			t.Errorf("align %d: first baseline should be %g, but %g", test.align, test.baseline, lines[0].Baseline)
+		}
+		bounds := ctx.TextBoxBounds(10, y, 200, "one\ntwo\nthree")
+		if bounds[1] > lines[0].Baseline-ascender+0.01 || bounds[3] < lines[2].Baseline-descender-0.01 {
+			t.Errorf("align %d: bounds %v should contain the rows", test.align, bounds)
+		}
+	}
+}
+
+// textPaintParams records text drawn with gradient and pattern paints.
+type textPaintParams struct {
+	testParams
+	paints     []Paint
+	fontImages []int
+}
+
+func (p *textPaintParams) renderTextPaint(paint *Paint, scissor *nvgScissor, fontImage int, vertexes []nvgVertex) {
+	p.paints = append(p.paints, *paint)
+	p.fontImages = append(p.fontImages, fontImage)
+	p.renderTriangleStrip(paint, scissor, vertexes)
+}
+
+func TestTextGradientFill(t *testing.T) {
+	params := &textPaintParams{}
+	ctx, err := createInternal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Delete()
+
+	ctx.BeginFrame(100, 100, 1)
+	loadTestFont(t, ctx)
+	ctx.SetFillColor(RGBA(255, 255, 255, 255))
+	ctx.Text(10, 50, "solid")
+	if len(params.paints) != 0 || len(params.triangles) != 1 {
+		t.Fatalf("solid text should be drawn as a plain triangle strip, but %d calls", len(params.paints))
+	}
+
+	inner := RGBA(255, 0, 0, 255)
+	outer := RGBA(0, 0, 255, 255)
+	ctx.SetFillPaint(LinearGradient(10, 0, 90, 0, inner, outer))
+	ctx.SetGlobalAlpha(0.5)
+	ctx.Text(10, 50, "gradient")
+	if len(params.paints) != 1 {
+		t.Fatalf("gradient text should be drawn with the paint, but %d calls", len(params.paints))
+	}
+	paint := params.paints[0]
+	if paint.image != 0 {
+		t.Errorf("gradient paint should not use an image, but %d", paint.image)
+	}
+	if params.fontImages[0] != ctx.fontImages[ctx.fontImageIdx] {
+		t.Errorf("glyph coverage should come from the font image %d, but %d", ctx.fontImages[ctx.fontImageIdx], params.fontImages[0])
+	}
+	if paint.innerColor.R != 1 || paint.outerColor.B != 1 || paint.innerColor.A != 0.5 || paint.outerColor.A != 0.5 {
+		t.Errorf("gradient colors should be kept with global alpha, but %v %v", paint.innerColor, paint.outerColor)
+	}
+	if len(params.triangles) != 2 || len(params.triangles[1]) == 0 {
+		t.Error("gradient text should submit glyph quads")
+	}
+	ctx.EndFrame()
+}
+
+func BenchmarkCullingOffscreenPaths(b *testing.B) {
+	for _, test := range []struct {
+		name    string
+		culling bool
+	}{{"Off", false}, {"On", true}} {
+		culling := test.culling
+		b.Run(test.name, func(b *testing.B) {
+			params := &testParams{}
+			ctx, err := createInternal(params)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer ctx.Delete()
+			ctx.SetCulling(culling)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				ctx.BeginFrame(100, 100, 1)
+				// Only one shape in ten is in the viewport.
+				for j := 0; j < 100; j++ {
+					x := float32(j%10) * 100
+					ctx.BeginPath()
+					ctx.Circle(x+50, 50, 40)
+					ctx.Fill()
+					ctx.Stroke()
+				}
+				ctx.EndFrame()
+				params.fills = params.fills[:0]
+				params.strokes = params.strokes[:0]
+			}
+		})
+	}
+}
+
+func BenchmarkFillStroke(b *testing.B) {
+	for _, test := range []struct {
+		name     string
+		combined bool
+	}{{"Separate", false}, {"Combined", true}} {
+		combined := test.combined
+		b.Run(test.name, func(b *testing.B) {
+			params := &NullParams{CountVertexes: true}
+			ctx, err := NewNullContextWithParams(params)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer ctx.Delete()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				ctx.BeginFrame(1000, 1000, 1)
+				ctx.SetStrokeWidth(3)
+				for j := 0; j < 100; j++ {
+					ctx.BeginPath()
+					ctx.RoundedRect(float32(j%10)*100+10, float32(j/10)*100+10, 80, 80, 20)
+					if combined {
+						ctx.FillStroke()
+					} else {
+						ctx.Fill()
+						ctx.Stroke()
+					}
+				}
+				ctx.EndFrame()
+			}
+			b.ReportMetric(float64(params.FillVertexes+params.StrokeVertexes)/float64(b.N), "vertexes/op")
+		})
+	}
+}
+
+// aaTestParams is a testParams which asks for anti-aliasing fringes.
+type aaTestParams struct {
+	testParams
+}
+
+func (p *aaTestParams) edgeAntiAlias() bool { return true }
+
+// fringeTestParams records the number of fringe vertexes of the filled paths.
+type fringeTestParams struct {
+	aaTestParams
+	fringes []int
+}
+
+func (p *fringeTestParams) renderFill(paint *Paint, scissor *nvgScissor, fringe float32, bounds [4]float32, paths []nvgPath) {
+	for i := range paths {
+		p.fringes = append(p.fringes, len(paths[i].strokes))
+	}
+}
+
+func TestFillStrokeKeepsFringe(t *testing.T) {
+	params := &fringeTestParams{}
+	ctx, err := createInternal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Delete()
+	ctx.BeginFrame(200, 200, 1)
+	ctx.SetStrokeWidth(4)
+	for _, fillStroke := range []bool{false, true} {
+		ctx.BeginPath()
+		ctx.Circle(100, 100, 50)
+		if fillStroke {
+			ctx.FillStroke()
+		} else {
+			ctx.Fill()
+		}
+	}
+	if len(params.fringes) != 2 || params.fringes[0] == 0 || params.fringes[1] != params.fringes[0] {
+		t.Errorf("FillStroke() should keep the fill fringe of Fill(), but %v", params.fringes)
+	}
+}
+
+func TestStrokeCapExtension(t *testing.T) {
+	// A diagonal line of length 100 along (0.6, 0.8).
+	const x0, y0, length = 10, 20, 100
+	const dx, dy = 0.6, 0.8
+	caps := []struct {
+		name   string
+		cap    LineCap
+		extend float32 // in half widths
+	}{
+		{"butt", Butt, 0},
+		{"square", Square, 1},
+		{"round", Round, 1},
+	}
+	for _, aa := range []bool{false, true} {
+		var params nvgParams = &testParams{}
+		if aa {
+			params = &aaTestParams{}
+		}
+		ctx, err := createInternal(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx.BeginFrame(200, 200, 1)
+		for _, c := range caps {
+			for _, width := range []float32{1, 2.5, 7, 20} {
+				ctx.SetStrokeWidth(width)
+				ctx.SetLineCap(c.cap)
+				ctx.BeginPath()
+				ctx.MoveTo(x0, y0)
+				ctx.LineTo(x0+dx*length, y0+dy*length)
+				ctx.Stroke()
+
+				// Project the vertexes on the line, the edge is halfway between inner and fringe vertexes.
+				minSolid, maxSolid := float32(1e6), float32(-1e6)
+				minAll, maxAll := float32(1e6), float32(-1e6)
+				for _, v := range ctx.cache.paths[0].strokes {
+					d := (v.x-x0)*dx + (v.y-y0)*dy
+					minAll, maxAll = minF(minAll, d), maxF(maxAll, d)
+					if v.v == 1 {
+						minSolid, maxSolid = minF(minSolid, d), maxF(maxSolid, d)
+					}
+				}
+				start, end := minAll, maxAll
+				tolerance := float32(1e-3)
+				if aa && c.cap != Round {
+					start, end = (minSolid+minAll)*0.5, (maxSolid+maxAll)*0.5
+				}
+				expected := c.extend * width * 0.5
+				if c.cap == Round {
+					if aa {
+						expected += ctx.fringeWidth * 0.5
+					}
+					tolerance = ctx.tessTol
+				}
+				if absF(-start-expected) > tolerance || absF(end-length-expected) > tolerance {
+					t.Errorf("aa=%v %s cap of width %g should extend %g, but %g and %g", aa, c.name, width, expected, -start, end-length)
+				}
+			}
+		}
+		ctx.EndFrame()
+		ctx.Delete()
+	}
+}
+
+func TestTabStops(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	loadTestFont(t, ctx)
+	ctx.SetTabStops([]float32{100})
+
+	// Both lines have the second column at the first stop.
+	for _, line := range []string{"a\tcolumn", "abcdef\tcolumn"} {
+		positions := ctx.TextGlyphPositions(10, 50, line)
+		tab := len(line) - len("\tcolumn")
+		if x := positions[tab+1].X; absF(x-110) > 0.5 {
+			t.Errorf("%q: column should start at 110, but %g", line, x)
+		}
+	}
+	advance, _ := ctx.TextBounds(10, 50, "abc\t")
+	if absF(advance-100) > 0.5 {
+		t.Errorf("advance up to the tab stop should be 100, but %g", advance)
+	}
+
+	rows := ctx.TextBreakLines("a\tcolumn\nabcdef\tcolumn", 1000)
+	if len(rows) != 2 {
+		t.Fatalf("text should have 2 rows, but %d", len(rows))
+	}
+	if absF(rows[0].Width-rows[1].Width) > 0.5 {
+		t.Errorf("rows aligned with tabs should have the same width, but %g and %g", rows[0].Width, rows[1].Width)
+	}
+
+	ctx.SetTabStops(nil)
+	if advance, _ := ctx.TextBounds(10, 50, "abc\t"); absF(advance-100) < 0.5 {
+		t.Error("tab without tab stops should not move the pen to a stop")
+	}
+	ctx.SetTabStops([]float32{100})
+	ctx.SetTabStops([]float32{100, 0})
+	if stops := ctx.TabStops(); stops != nil {
+		t.Errorf("invalid tab stop width should remove the tab stops, but %v", stops)
+	}
+}
+
+func TestTextDecoration(t *testing.T) {
+	ctx, p := newTestContext(t)
+	loadTestFont(t, ctx)
+	_, descender, _ := ctx.TextMetrics()
+
+	n := len(p.fills)
+	ctx.Text(10, 50, "abc")
+	if len(p.fills) != n {
+		t.Fatalf("text without decoration should not fill, but %d fills", len(p.fills)-n)
+	}
+
+	ctx.SetTextDecoration(DecorationUnderline)
+	n = len(p.fills)
+	advance := ctx.Text(10, 50, "abc") - 10
+	if len(p.fills) != n+1 {
+		t.Fatalf("underline should be 1 fill, but %d", len(p.fills)-n)
+	}
+	minX, minY, maxX, maxY := float32(1e6), float32(1e6), float32(-1e6), float32(-1e6)
+	for _, v := range p.fills[n] {
+		minX, minY = minF(minX, v.x), minF(minY, v.y)
+		maxX, maxY = maxF(maxX, v.x), maxF(maxY, v.y)
+	}
+	if center := (minY + maxY) * 0.5; absF(center-(50-descender*0.5)) > 0.01 {
+		t.Errorf("underline should be at %g below the baseline, but %g", -descender*0.5, center-50)
+	}
+	if thickness := maxY - minY; absF(thickness-20.0/16) > 0.01 {
+		t.Errorf("underline should be %g thick, but %g", 20.0/16, thickness)
+	}
+	if absF(minX-10) > 0.01 || absF(maxX-minX-advance) > 0.01 {
+		t.Errorf("underline should span 10 to %g, but %g to %g", 10+advance, minX, maxX)
+	}
+
+	ctx.SetTextDecoration(DecorationUnderline | DecorationStrikethrough)
+	n = len(p.fills)
+	ctx.Text(10, 50, "abc")
+	if len(p.fills) != n+2 {
+		t.Errorf("underline and strikethrough should be 2 fills, but %d", len(p.fills)-n)
+	}
+}
+
+func TestBeginFrameInvalidDimensions(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	for _, ratio := range []float32{0, -1} {
+		ctx.BeginFrame(800, 600, ratio)
+		for name, v := range map[string]float32{"tessTol": ctx.tessTol, "distTol": ctx.distTol, "fringeWidth": ctx.fringeWidth} {
+			// NaN and Inf don't pass this.
+			if !(v > 0) || v-v != 0 {
+				t.Errorf("ratio %g: %s should be positive and finite, but %g", ratio, name, v)
+			}
+		}
+	}
+	ctx.BeginFrame(0, -10, 1)
+	if ctx.viewSize != [2]float32{1, 1} {
+		t.Errorf("non-positive dimensions should be clamped to 1, but %v", ctx.viewSize)
+	}
+}
+
+// alphaParams records the alpha of paints of triangle strips, i.e. text.
+type alphaParams struct {
+	testParams
+	alphas []float32
+}
+
+func (p *alphaParams) renderTriangleStrip(paint *Paint, scissor *nvgScissor, vertexes []nvgVertex) {
+	p.alphas = append(p.alphas, paint.innerColor.A)
+	p.testParams.renderTriangleStrip(paint, scissor, vertexes)
+}
+
+func TestTextGlobalAlpha(t *testing.T) {
+	params := &alphaParams{}
+	ctx, err := createInternal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Delete()
+
+	ctx.BeginFrame(100, 100, 1)
+	loadTestFont(t, ctx)
+	ctx.SetFillColor(RGBAf(1, 1, 1, 1))
+	ctx.Save()
+	ctx.SetGlobalAlpha(0.5)
+	ctx.Save()
+	ctx.SetGlobalAlpha(0.5)
+	ctx.Text(10, 50, "a")
+	ctx.Text(10, 50, "a")
+	ctx.MultiplyGlobalAlpha(0.5)
+	ctx.Text(10, 50, "a")
+	ctx.Restore()
+	ctx.Restore()
+	ctx.Text(10, 50, "a")
+
+	expected := []float32{0.5, 0.5, 0.25, 1}
+	if len(params.alphas) != len(expected) {
+		t.Fatalf("text should be drawn %d times, but %d", len(expected), len(params.alphas))
+	}
+	for i, alpha := range expected {
+		if absF(params.alphas[i]-alpha) > 1e-6 {
+			t.Errorf("text %d should have alpha %g, but %g", i, alpha, params.alphas[i])
+		}
+	}
+}
+
+func TestArcToNearlyCollinear(t *testing.T) {
+	ctx, p := newTestContext(t)
+	cases := [][4]float32{
+		{100, 1e-5, 200, 0},     // almost straight on
+		{100, 0, 0, 1e-6},       // almost straight back
+		{100, 0, 100.00001, 0},  // corner point almost at the end point
+		{100, 1e-7, -100, 1e-7}, // back beyond the start point
+	}
+	for _, c := range cases {
+		ctx.BeginPath()
+		ctx.MoveTo(0, 0)
+		ctx.ArcTo(c[0], c[1], c[2], c[3], 10)
+		for i, v := range ctx.commands {
+			if v-v != 0 {
+				t.Fatalf("ArcTo%v: command value %d is not finite: %g", c, i, v)
+			}
+		}
+		if len(ctx.commands) != 6 || ctx.commands[3] != float32(nvgLINETO) {
+			t.Errorf("ArcTo%v should be a line to the corner, but %v", c, ctx.commands)
+		}
+		ctx.LineTo(0, 50)
+		n := len(p.strokes)
+		ctx.Stroke()
+		for _, strip := range p.strokes[n:] {
+			for _, v := range strip {
+				if v.x-v.x != 0 || v.y-v.y != 0 {
+					t.Fatalf("ArcTo%v: stroke vertex is not finite: (%g, %g)", c, v.x, v.y)
+				}
+			}
+		}
+	}
+}
+
+func TestNonFiniteCoordinates(t *testing.T) {
+	ctx, p := newTestContext(t)
+	nan := float32(0)
+	nan /= nan
+	ctx.BeginPath()
+	ctx.MoveTo(10, 10)
+	ctx.LineTo(nan, 50)
+	ctx.LineTo(50, 10)
+	ctx.LineTo(50, 50)
+	ctx.ClosePath()
+	ctx.MoveTo(nan, nan)
+	ctx.Rect(100, 100, nan, 10)
+	ctx.Fill()
+	ctx.Stroke()
+
+	bounds := ctx.cache.bounds
+	if bounds != [4]float32{10, 10, 50, 50} {
+		t.Errorf("bounds should be of the finite points, but %v", bounds)
+	}
+	if len(p.fills) != 1 || len(p.fills[0]) != 3 {
+		t.Fatalf("the triangle should be filled, but %v", p.fills)
+	}
+	for _, v := range p.fills[0] {
+		if !isFiniteF(v.x) || !isFiniteF(v.y) {
+			t.Errorf("fill vertex should be finite, but (%g, %g)", v.x, v.y)
+		}
+	}
+	if len(p.strokes) != 1 {
+		t.Errorf("the triangle should be stroked, but %d strokes", len(p.strokes))
+	}
+
+	// A horizontal segment which overflows after the transform doesn't snap the previous point.
+	ctx.SetSnapToPixel(true)
+	ctx.BeginPath()
+	ctx.MoveTo(10, 10.2)
+	ctx.Scale(1e38, 1)
+	ctx.LineTo(10, 10.2)
+	if len(ctx.commands) != 3 || ctx.commands[2] != 10.2 {
+		t.Errorf("overflowing segment should be dropped without snapping the move to, but %v", ctx.commands)
+	}
+}
+
+func TestSimplifyPath(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	ctx.BeginPath()
+	// Open polyline along a line with small wiggles, and a corner at (100, 0).
+	ctx.MoveTo(0, 0)
+	for x := float32(10); x < 100; x += 10 {
+		ctx.LineTo(x, 0.1)
+	}
+	ctx.LineTo(100, 0)
+	ctx.LineTo(100, 100)
+	// Closed square with extra points on its sides.
+	ctx.MoveTo(200, 0)
+	ctx.LineTo(250, 0)
+	ctx.LineTo(300, 0)
+	ctx.LineTo(300, 50)
+	ctx.LineTo(300, 100)
+	ctx.LineTo(200, 100)
+	ctx.ClosePath()
+	ctx.SimplifyPath(0.5)
+
+	var paths [][]float32
+	var closed []bool
+	ctx.WalkCommands(func(cmd CommandKind, pts []float32) {
+		switch cmd {
+		case CommandMoveTo:
+			paths = append(paths, append([]float32(nil), pts...))
+			closed = append(closed, false)
+		case CommandLineTo:
+			paths[len(paths)-1] = append(paths[len(paths)-1], pts...)
+		case CommandClose:
+			closed[len(closed)-1] = true
+		default:
+			t.Errorf("simplified path should have only lines, but command %d", cmd)
+		}
+	})
+	expected := [][]float32{{0, 0, 100, 0, 100, 100}, {200, 0, 300, 0, 300, 100, 200, 100}}
+	if len(paths) != len(expected) {
+		t.Fatalf("path should have %d sub-paths, but %d", len(expected), len(paths))
+	}
+	for i := range expected {
+		if len(paths[i]) != len(expected[i]) {
+			t.Errorf("sub-path %d should be %v, but %v", i, expected[i], paths[i])
+			continue
+		}
+		for j := range expected[i] {
+			if absF(paths[i][j]-expected[i][j]) > 1e-3 {
+				t.Errorf("sub-path %d should be %v, but %v", i, expected[i], paths[i])
+				break
+			}
+		}
+	}
+	if closed[0] || !closed[1] {
+		t.Errorf("only the second sub-path should be closed, but %v", closed)
+	}
+}
+
+func TestTextBoxCenterAlign(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	loadTestFont(t, ctx)
+	ctx.SetTextAlign(AlignCenter | AlignBaseline)
+
+	// Rows broken at spaces and a word longer than the row, which is broken mid-word.
+	const breakRowWidth = 120
+	str := "To AVAWAY Ta Yo Wolfeschlegelsteinhausenbergerdorff VA To Ty a Va"
+	lines := ctx.TextBoxLines(10, 50, breakRowWidth, str)
+	if len(lines) < 4 {
+		t.Fatalf("text box should wrap to several rows, but %d", len(lines))
+	}
+	ctx.SetTextAlign(AlignLeft | AlignBaseline)
+	for i, line := range lines {
+		row := line.Runes[line.StartIndex:line.EndIndex]
+		if len(row) == 0 || row[0] == ' ' || row[len(row)-1] == ' ' {
+			t.Errorf("row %d %q should be trimmed", i, string(row))
+			continue
+		}
+		drawnWidth, _ := ctx.TextBounds(0, 0, string(row))
+		if absF(line.Offset-(breakRowWidth-drawnWidth)*0.5) > 0.25 {
+			t.Errorf("row %d %q: offset should be %g, but %g", i, string(row), (breakRowWidth-drawnWidth)*0.5, line.Offset)
+		}
+		if absF(line.X-10-line.Offset) > 1e-3 {
+			t.Errorf("row %d: left edge should be %g, but %g", i, 10+line.Offset, line.X)
+		}
+		if right := ctx.TextRune(line.X, line.Y, row); absF(right-(10+breakRowWidth-line.Offset)) > 0.25 {
+			t.Errorf("row %d %q: right edge should be %g, but %g", i, string(row), 10+breakRowWidth-line.Offset, right)
+		}
+	}
+}
+
+func TestUnionRects(t *testing.T) {
+	ctx, params := newTestContext(t)
+	ctx.BeginPath()
+	// Overlapping horizontal and vertical bars make an L-shape.
+	ctx.UnionRects([][4]float32{{0, 0, 100, 20}, {0, 0, 20, 100}})
+
+	var points []float32
+	var windings []float32
+	var moves int
+	ctx.WalkCommands(func(cmd CommandKind, pts []float32) {
+		switch cmd {
+		case CommandMoveTo:
+			moves++
+			points = append(points, pts...)
+		case CommandLineTo:
+			points = append(points, pts...)
+		case CommandWinding:
+			windings = append(windings, pts...)
+		}
+	})
+	if moves != 1 || len(windings) != 1 || windings[0] != float32(Solid) {
+		t.Fatalf("union should be a single solid sub-path, but %d sub-paths with windings %v", moves, windings)
+	}
+	expected := [][2]float32{{0, 0}, {100, 0}, {100, 20}, {20, 20}, {20, 100}, {0, 100}}
+	if len(points) != len(expected)*2 {
+		t.Fatalf("L-shape should have %d corners, but %v", len(expected), points)
+	}
+	for _, e := range expected {
+		found := false
+		for k := 0; k < len(points); k += 2 {
+			found = found || points[k] == e[0] && points[k+1] == e[1]
+		}
+		if !found {
+			t.Errorf("outline %v should have corner %v", points, e)
+		}
+	}
+
+	ctx.Fill()
+	if len(params.fills) != 1 || len(params.fills[0]) != len(expected) {
+		t.Errorf("fill should have one path of %d vertexes, but %d paths", len(expected), len(params.fills))
+	}
+}
+
+func TestTextSnapAxes(t *testing.T) {
+	firstGlyph := func(x, y float32) nvgVertex {
+		ctx, params := newTestContext(t)
+		loadTestFont(t, ctx)
+		ctx.SetTextSnapAxes(false, true)
+		if snapX, snapY := ctx.TextSnapAxes(); snapX || !snapY || ctx.TextPixelSnap() {
+			t.Fatalf("only vertical snapping should be enabled, but %v %v", snapX, snapY)
+		}
+		ctx.Text(x, y, "A")
+		if len(params.triangles) == 0 || len(params.triangles[0]) == 0 {
+			t.Fatal("no glyph is rendered")
+		}
+		return params.triangles[0][1]
+	}
+	base := firstGlyph(10, 50)
+	for _, dx := range []float32{0.25, 0.5, 0.75} {
+		v := firstGlyph(10+dx, 50+dx)
+		if absF(v.x-base.x-dx) > 1e-3 {
+			t.Errorf("glyph x should move by %g without horizontal snapping, but %g", dx, v.x-base.x)
+		}
+		if v.y != base.y {
+			t.Errorf("glyph y should stay snapped at %g, but %g", base.y, v.y)
+		}
+	}
+}
+
+func TestTextBoxLimited(t *testing.T) {
+	ctx, params := newTestContext(t)
+	loadTestFont(t, ctx)
+
+	const breakRowWidth = 120
+	str := "The quick brown fox jumps over the lazy dog and keeps running far away"
+	rows := len(ctx.TextBoxLines(10, 50, breakRowWidth, str))
+	if rows < 4 {
+		t.Fatalf("text box should wrap to several rows, but %d", rows)
+	}
+	if ctx.TextBoxLimited(10, 50, breakRowWidth, str, rows) {
+		t.Error("text box with all rows should not be truncated")
+	}
+	if len(params.triangles) != rows {
+		t.Fatalf("all %d rows should be drawn, but %d", rows, len(params.triangles))
+	}
+
+	params.triangles = nil
+	if !ctx.TextBoxLimited(10, 50, breakRowWidth, str, 2) {
+		t.Error("text box with less rows should be truncated")
+	}
+	if len(params.triangles) != 2 {
+		t.Fatalf("2 rows should be drawn, but %d", len(params.triangles))
+	}
+	var right float32
+	for _, v := range params.triangles[1] {
+		right = maxF(right, v.x)
+	}
+	if right > 10+breakRowWidth+1 {
+		t.Errorf("ellipsized row should fit in the box, but reaches %g", right)
+	}
+	lines, _ := ctx.textBoxLines(10, 50, breakRowWidth, str, 2)
+	last := lines[1].Runes[lines[1].StartIndex:lines[1].EndIndex]
+	if last[len(last)-1] != '…' || last[len(last)-2] == ' ' {
+		t.Errorf("last row should end with an ellipsis after a character, but %q", string(last))
+	}
+}
+
+// paletteParams looks up colors of paletted images like the GL backend.
+type paletteParams struct {
+	testParams
+	palettes map[int][]byte
+}
+
+func (p *paletteParams) renderSetTexturePalette(image int, palette []Color) error {
+	if p.palettes == nil {
+		p.palettes = make(map[int][]byte)
+	}
+	p.palettes[image] = paletteData(palette)
+	return nil
+}
+
+func TestPalettedImage(t *testing.T) {
+	indices := []byte{0, 1, 2, 1}
+	palette := []Color{RGBA(255, 0, 0, 255), RGBA(0, 255, 0, 255), RGBA(0, 0, 255, 128)}
+
+	// Without palette support in the backend, colors are looked up when the image is uploaded.
+	ctx, params := newTestContext(t)
+	img := ctx.CreatePalettedImage(2, 2, indices, palette, 0)
+	if img == 0 {
+		t.Fatal("paletted image should be created")
+	}
+	want := []byte{255, 0, 0, 255, 0, 255, 0, 255, 0, 0, 255, 128, 0, 255, 0, 255}
+	if data := params.data[img]; string(data) != string(want) {
+		t.Errorf("image should have colors %v, but %v", want, data)
+	}
+	if err := ctx.SetPalette(img, palette[1:]); err != nil {
+		t.Errorf("palette of paletted image should be set, but %v", err)
+	}
+	alpha := ctx.CreateImageAlpha(2, 2, 0, indices)
+	if err := ctx.SetPalette(alpha, palette); err == nil {
+		t.Error("palette of alpha image should not be set")
+	}
+
+	// With palette support, the backend gets the indices and the palette.
+	pparams := &paletteParams{}
+	pctx, err := createInternal(pparams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pctx.Delete()
+	img = pctx.CreatePalettedImage(2, 2, indices, palette, 0)
+	if tex := pparams.textures[img]; nvgTextureType(tex[2]) != nvgTextureALPHA || string(pparams.data[img]) != string(indices) {
+		t.Errorf("paletted image should be alpha texture of indices, but %v %v", tex, pparams.data[img])
+	}
+	if err := pctx.SetPalette(img, palette[2:]); err != nil {
+		t.Fatal(err)
+	}
+	if entry := pparams.palettes[img][:4]; string(entry) != string([]byte{0, 0, 255, 128}) {
+		t.Errorf("first palette entry should be replaced, but %v", entry)
+	}
+	alpha = pctx.CreateImageAlpha(2, 2, 0, indices)
+	if err := pctx.SetPalette(alpha, palette); err == nil {
+		t.Error("palette of alpha image should not be set by the backend")
+	}
+	if _, ok := pparams.palettes[alpha]; ok {
+		t.Error("backend should not get palette of alpha image")
+	}
+}
+
+func TestApplyStyleRoundTrip(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	if style := ctx.CurrentStyle(); style != DefaultStyle() {
+		t.Errorf("new frame should have the default style, but %+v", style)
+	}
+	zero := Style{LineJoin: Round}
+	ctx.ApplyStyle(zero)
+	if style := ctx.CurrentStyle(); style != zero {
+		t.Errorf("zero fields should be kept, but %+v", style)
+	}
+	ctx.ApplyStyle(ctx.CurrentStyle())
+	if style := ctx.CurrentStyle(); style != zero {
+		t.Errorf("ApplyStyle(CurrentStyle()) should change nothing, but %+v", style)
+	}
+	custom := DefaultStyle()
+	custom.StrokeWidth = 3
+	custom.FillColor = RGBAf(1, 0, 0, 0.5)
+	ctx.ApplyStyle(custom)
+	if style := ctx.CurrentStyle(); style != custom {
+		t.Errorf("style should be %+v, but %+v", custom, style)
+	}
+}
+
+func TestColorTemperature(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	tests := []struct {
+		kelvin  float32
+		r, g, b float32
+	}{
+		{1000, 1, 0.266, 0},      // Deep orange.
+		{6500, 1, 0.996, 0.981},  // About daylight white.
+		{500, 1, 0.266, 0},       // Clamped to 1000K.
+		{12000, 0.749, 0.829, 1}, // Bluish.
+		{20000, 0.749, 0.829, 1}, // Clamped to 12000K.
+	}
+	for _, test := range tests {
+		ctx.SetFillColor(ColorTemperature(test.kelvin))
+		c := ctx.CurrentStyle().FillColor
+		if absF(c.R-test.r) > 0.005 || absF(c.G-test.g) > 0.005 || absF(c.B-test.b) > 0.005 || c.A != 1 {
+			t.Errorf("%gK should be opaque (%g, %g, %g), but %v", test.kelvin, test.r, test.g, test.b, c)
+		}
+	}
+}
+
+func TestFontSizePt(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	ctx.SetFontSizePt(12)
+	if size := ctx.FontSize(); absF(size-16) > 1e-4 {
+		t.Errorf("12pt should be 16px at 96 dpi, but %g", size)
+	}
+	ctx.SetDPI(144)
+	ctx.SetFontSizePt(12)
+	if size := ctx.FontSize(); absF(size-24) > 1e-4 {
+		t.Errorf("12pt should be 24px at 144 dpi, but %g", size)
+	}
+	if pt := ctx.FontSizePt(); absF(pt-12) > 1e-4 {
+		t.Errorf("font size should read back as 12pt, but %g", pt)
+	}
+	ctx.SetFontSize(36)
+	if pt := ctx.FontSizePt(); absF(pt-18) > 1e-4 {
+		t.Errorf("36px should be 18pt at 144 dpi, but %g", pt)
+	}
+	nan := float32(math.NaN())
+	for _, dpi := range []float32{0, -72, nan, float32(math.Inf(1))} {
+		ctx.SetDPI(dpi)
+		if ctx.DPI() != 144 {
+			t.Errorf("dpi %g should be ignored, but dpi is %g", dpi, ctx.DPI())
+		}
+	}
+}
+
+func TestDrawMetaballs(t *testing.T) {
+	ctx, params := newTestContext(t)
+	// loops returns the areas of the outlines filled by the last DrawMetaballs() call.
+	loops := func(circles [][3]float32, smoothing float32) []float32 {
+		params.fills = nil
+		ctx.DrawMetaballs(circles, smoothing, RGBA(255, 0, 0, 255))
+		var areas []float32
+		for _, fill := range params.fills {
+			loop := make([]float32, 0, len(fill)*2)
+			for _, v := range fill {
+				loop = append(loop, v.x, v.y)
+			}
+			areas = append(areas, absF(loopArea(loop)))
+		}
+		return areas
+	}
+	circleArea := PI * 20 * 20
+	// Lens of two circles of radius 20 at distance 20.
+	lens := 2*20*20*acosF(0.5) - 10*sqrtF(4*20*20-20*20)
+	union := 2*circleArea - lens
+
+	if areas := loops([][3]float32{{50, 50, 20}, {150, 50, 20}}, 0); len(areas) != 2 {
+		t.Errorf("disjoint circles should have 2 outlines, but %d", len(areas))
+	} else if absF(areas[0]-circleArea) > circleArea*0.02 || absF(areas[1]-circleArea) > circleArea*0.02 {
+		t.Errorf("disjoint circles should have area %g each, but %v", circleArea, areas)
+	}
+
+	overlapping := [][3]float32{{50, 50, 20}, {70, 50, 20}}
+	if areas := loops(overlapping, 0); len(areas) != 1 {
+		t.Errorf("overlapping circles should have 1 outline, but %d", len(areas))
+	} else if absF(areas[0]-union) > union*0.02 {
+		t.Errorf("zero smoothing should be the plain union of area %g, but %g", union, areas[0])
+	}
+	if areas := loops(overlapping, 20); len(areas) != 1 {
+		t.Errorf("smoothed circles should have 1 outline, but %d", len(areas))
+	} else if areas[0] < union*1.02 {
+		t.Errorf("smoothed union should be bigger than the plain union of area %g, but %g", union, areas[0])
+	}
+
+	// Circles far from the origin keep a fine grid.
+	if areas := loops([][3]float32{{2e6, 50, 20}}, 0); len(areas) != 1 || absF(areas[0]-circleArea) > circleArea*0.05 {
+		t.Errorf("circle at x=2e6 should have area %g, but %v", circleArea, areas)
+	}
+}
+
+func TestRegisterImageDecoder(t *testing.T) {
+	ctx, params := newTestContext(t)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	size := func(handle int) [2]int {
+		if handle == 0 {
+			t.Fatalf("image should be created, but %v", ctx.LastImageError())
+		}
+		tex := params.textures[handle]
+		return [2]int{tex[0], tex[1]}
+	}
+
+	// The registered decoder is tried before the PNG decoder of the image package.
+	ctx.RegisterImageDecoder("fake", func([]byte) (image.Image, error) {
+		return image.NewNRGBA(image.Rect(0, 0, 3, 5)), nil
+	})
+	if s := size(ctx.CreateImageFromMemory(0, data)); s != [2]int{3, 5} {
+		t.Errorf("registered decoder should decode the image, but its size is %v", s)
+	}
+
+	ctx.RegisterImageDecoder("fake", nil)
+	if s := size(ctx.CreateImageFromMemory(0, data)); s != [2]int{2, 2} {
+		t.Errorf("unregistered decoder should not be used, but the image size is %v", s)
+	}
+
+	// Decoders which fail fall through to the image package.
+	called := false
+	ctx.RegisterImageDecoder("broken", func([]byte) (image.Image, error) {
+		called = true
+		return nil, errors.New("not this format")
+	})
+	if s := size(ctx.CreateImageFromMemory(0, data)); s != [2]int{2, 2} || !called {
+		t.Errorf("failing decoder should fall through to PNG of size 2x2, but %v (called %v)", s, called)
+	}
+}
+
+func TestSnapToPixelRect(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	ctx.SetSnapToPixel(true)
+	for _, ratio := range []float32{1, 2} {
+		ctx.BeginFrame(800, 600, ratio)
+		ctx.BeginPath()
+		ctx.Rect(10.2, 20.7, 30.4, 40.1)
+		var points [][2]float32
+		ctx.WalkCommands(func(cmd CommandKind, pts []float32) {
+			if len(pts) >= 2 && cmd != CommandWinding {
+				points = append(points, [2]float32{pts[0], pts[1]})
+			}
+		})
+		if len(points) != 4 {
+			t.Fatalf("ratio %g: rect should have 4 points, but %v", ratio, points)
+		}
+		// All four edges, including the top one closed back to the move to, are on device pixel centers.
+		for _, p := range points {
+			for _, v := range p {
+				if f := v*ratio - 0.5; absF(f-floorF(f+0.5)) > 1e-4 {
+					t.Errorf("ratio %g: rect corner %v should be on half pixels", ratio, p)
+				}
+			}
+		}
+		if points[0][1] != points[3][1] || points[1][1] != points[2][1] || points[0][0] != points[1][0] || points[2][0] != points[3][0] {
+			t.Errorf("ratio %g: rect edges should stay axis aligned, but %v", ratio, points)
+		}
+	}
+
+	// Diagonals are not snapped.
+	ctx.BeginFrame(800, 600, 1)
+	ctx.BeginPath()
+	ctx.MoveTo(10.2, 10.2)
+	ctx.LineTo(20.7, 30.7)
+	ctx.ClosePath()
+	if ctx.commands[1] != 10.2 || ctx.commands[5] != 30.7 {
+		t.Errorf("diagonal should not be snapped, but %v", ctx.commands)
+	}
+}
+
+func TestMissingGlyphHandler(t *testing.T) {
+	ctx, _ := newTestContext(t)
+	loadTestFont(t, ctx)
+	var reported []rune
+	ctx.SetMissingGlyphHandler(func(r rune, fontID int) {
+		reported = append(reported, r)
+	})
+	tests := []struct {
+		name string
+		draw func()
+	}{
+		{"Text", func() { ctx.Text(10, 50, "a\u4e00b\u4e00") }},
+		{"TextBounds", func() { ctx.TextBounds(10, 50, "a\u4e00b\u4e00") }},
+		{"TextBox", func() { ctx.TextBox(10, 50, 30, "aaaa \u4e00 bbbb \u4e00") }},
+		{"TextBoxLimited", func() { ctx.TextBoxLimited(10, 50, 30, "aaaa \u4e00 bbbb \u4e00", 5) }},
+		{"TextStroke", func() { ctx.TextStroke(10, 50, "a\u4e00\n", RGBA(0, 0, 0, 255), 2) }},
+	}
+	for _, test := range tests {
+		reported = nil
+		test.draw()
+		if len(reported) != 1 || reported[0] != '\u4e00' {
+			t.Errorf("%s should report U+4E00 once, but %q", test.name, reported)
+		}
+	}
+	if lines := ctx.TextBoxLines(10, 50, 30, "aaaa \u4e00 bbbb \u4e00"); len(lines) < 2 {
+		t.Errorf("the missing glyphs should be in several rows, but %d", len(lines))
+	}
+	reported = nil
+	ctx.Text(10, 50, "ab\n")
+	if len(reported) != 0 {
+		t.Errorf("present glyphs and control characters should not be reported, but %q", reported)
+	}
+}
+
+func TestLayer(t *testing.T) {
+	ctx, params := newTestContext(t)
+	// Each draw is a rectangle at its own x.
+	draw := func(x float32) {
+		ctx.BeginPath()
+		ctx.Rect(x, 0, 1, 1)
+		ctx.Fill()
+	}
+	order := func() []float32 {
+		var xs []float32
+		for _, fill := range params.fills {
+			x := fill[0].x
+			for _, v := range fill {
+				x = minF(x, v.x)
+			}
+			xs = append(xs, x)
+		}
+		params.fills = nil
+		return xs
+	}
+	check := func(name string, expected ...float32) {
+		t.Helper()
+		xs := order()
+		if len(xs) != len(expected) {
+			t.Errorf("%s should draw %v, but %v", name, expected, xs)
+			return
+		}
+		for i := range xs {
+			if xs[i] != expected[i] {
+				t.Errorf("%s should draw %v, but %v", name, expected, xs)
+				return
+			}
+		}
+	}
+
+	draw(0)
+	ctx.Layer(2, func() { draw(1) })
+	ctx.Layer(-1, func() { draw(2) })
+	draw(3)
+	ctx.Layer(1, func() { draw(4) })
+	check("before EndFrame", 0, 3)
+	ctx.EndFrame()
+	check("layers in increasing z over the other content", 2, 4, 1)
+
+	ctx.BeginFrame(800, 600, 1)
+	ctx.Layer(1, func() { draw(0) })
+	ctx.Layer(0, func() { draw(1) })
+	ctx.Layer(1, func() { draw(2) })
+	ctx.Layer(0, func() { draw(3) })
+	ctx.EndFrame()
+	check("equal z in call order", 1, 3, 0, 2)
+
+	ctx.BeginFrame(800, 600, 1)
+	ctx.Layer(1, func() {
+		draw(0)
+		ctx.Layer(3, func() { draw(1) })
+		ctx.Layer(0, func() { draw(2) })
+		draw(3)
+	})
+	ctx.Layer(2, func() { draw(4) })
+	ctx.EndFrame()
+	check("nested layers with their own z", 2, 0, 3, 4, 1)
+
+	ctx.BeginFrame(800, 600, 1)
+	ctx.Layer(1, func() { draw(0) })
+	ctx.CancelFrame()
+	ctx.EndFrame()
+	check("canceled frame")
+	ctx.BeginFrame(800, 600, 1)
+	ctx.EndFrame()
+	check("frame after the canceled one")
+}
+
+// premultipliedParams is a testParams which records the premultiplied output flag.
+type premultipliedParams struct {
+	testParams
+	premultiplied []bool
+}
+
+func (p *premultipliedParams) setPremultipliedOutput(enabled bool) {
+	p.premultiplied = append(p.premultiplied, enabled)
+}
+
+func TestPremultipliedOutput(t *testing.T) {
+	params := &premultipliedParams{}
+	ctx, err := createInternal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Delete()
+	if ctx.PremultipliedOutput() {
+		t.Error("output should be straight by default")
+	}
+	ctx.SetPremultipliedOutput(true)
+	if !ctx.PremultipliedOutput() {
+		t.Error("output should be premultiplied")
+	}
+	ctx.SetPremultipliedOutput(false)
+	if ctx.PremultipliedOutput() {
+		t.Error("output should be straight again")
+	}
+	if len(params.premultiplied) != 2 || !params.premultiplied[0] || params.premultiplied[1] {
+		t.Errorf("backend should get true then false, but %v", params.premultiplied)
+	}
+
+	// Backends without the switch keep the flag in the context only.
+	other, _ := newTestContext(t)
+	other.SetPremultipliedOutput(true)
+	if !other.PremultipliedOutput() {
+		t.Error("output should be premultiplied")
+	}
+}
+
+func TestCreateImageEXIFOrientation(t *testing.T) {
+	// 16x8 image, red on the left and blue on the right.
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 16; x++ {
+			c := color.NRGBA{255, 0, 0, 255}
+			if x >= 8 {
+				c = color.NRGBA{0, 0, 255, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+	// APP1 segment with big-endian TIFF data whose IFD0 has orientation 6 (rotate 90° clockwise to view).
+	exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08" +
+		"\x00\x01" + "\x01\x12\x00\x03\x00\x00\x00\x01\x00\x06\x00\x00" + "\x00\x00\x00\x00")
+	segment := append([]byte{0xff, 0xe1, 0, byte(len(exif) + 2)}, exif...)
+	data := append(append(append([]byte(nil), encoded.Bytes()[:2]...), segment...), encoded.Bytes()[2:]...)
+
+	ctx, p := newTestContext(t)
+	handle := ctx.CreateImageFromMemory(0, data)
+	if handle == 0 {
+		t.Fatal("can't create image from EXIF JPEG")
+	}
+	if w, h, _ := ctx.ImageSize(handle); w != 8 || h != 16 {
+		t.Fatalf("rotated image should be 8x16, but %dx%d", w, h)
+	}
+	// The left half is on the top after the rotation.
+	pix := p.data[handle]
+	top, bottom := pix[(2*8+4)*4:], pix[(13*8+4)*4:]
+	if top[0] < 200 || top[2] > 50 || bottom[0] > 50 || bottom[2] < 200 {
+		t.Errorf("image should be red on the top and blue on the bottom, but %v and %v", top[:4], bottom[:4])
+	}
+}
@@ -0,0 +1,121 @@
+package nanovgo
+
+import "errors"
+
+// NewNullContext makes new NanoVGo context that tessellates paths and lays out text like the other
+// backends but doesn't render anything. It is meant for benchmarks and tests without GPU.
+func NewNullContext() (*Context, error) {
+	return NewNullContextWithParams(&NullParams{})
+}
+
+// NewNullContextWithParams makes new NanoVGo context like NewNullContext() which submits the geometry
+// to params, e.g. to count vertexes.
+func NewNullContextWithParams(params *NullParams) (*Context, error) {
+	return createInternal(params)
+}
+
+// NullParams is a backend which doesn't render anything. When CountVertexes is set, it counts the
+// vertexes submitted by fills (with their anti-aliasing fringes), strokes and triangles (e.g. text)
+// since the last Reset().
+type NullParams struct {
+	CountVertexes    bool
+	FillVertexes     int
+	StrokeVertexes   int
+	TriangleVertexes int
+
+	textures  map[int][2]int
+	textureID int
+}
+
+// Reset clears the vertex counters.
+func (p *NullParams) Reset() {
+	p.FillVertexes = 0
+	p.StrokeVertexes = 0
+	p.TriangleVertexes = 0
+}
+
+func (p *NullParams) edgeAntiAlias() bool {
+	// Like a GL context created with AntiAlias, so that fringes are tessellated too.
+	return true
+}
+
+func (p *NullParams) renderCreate() error {
+	p.textures = make(map[int][2]int)
+	return nil
+}
+
+func (p *NullParams) renderCreateTexture(texType nvgTextureType, w, h int, flags ImageFlags, data []byte) int {
+	p.textureID++
+	p.textures[p.textureID] = [2]int{w, h}
+	return p.textureID
+}
+
+func (p *NullParams) renderDeleteTexture(image int) error {
+	if _, ok := p.textures[image]; !ok {
+		return errors.New("invalid texture in NullParams.renderDeleteTexture")
+	}
+	delete(p.textures, image)
+	return nil
+}
+
+func (p *NullParams) renderUpdateTexture(image, x, y, w, h int, data []byte) error {
+	if _, ok := p.textures[image]; !ok {
+		return errors.New("invalid texture in NullParams.renderUpdateTexture")
+	}
+	return nil
+}
+
+func (p *NullParams) renderGetTextureSize(image int) (int, int, error) {
+	size, ok := p.textures[image]
+	if !ok {
+		return -1, -1, errors.New("invalid texture in NullParams.renderGetTextureSize")
+	}
+	return size[0], size[1], nil
+}
+
+func (p *NullParams) renderViewport(width, height int) {
+}
+
+func (p *NullParams) renderCancel() {
+}
+
+func (p *NullParams) renderFlush() {
+}
+
+func (p *NullParams) renderFill(paint *Paint, scissor *nvgScissor, fringe float32, bounds [4]float32, paths []nvgPath) {
+	if !p.CountVertexes {
+		return
+	}
+	for i := range paths {
+		p.FillVertexes += len(paths[i].fills) + len(paths[i].strokes)
+	}
+}
+
+func (p *NullParams) renderStroke(paint *Paint, scissor *nvgScissor, fringe float32, strokeWidth float32, paths []nvgPath) {
+	if !p.CountVertexes {
+		return
+	}
+	for i := range paths {
+		p.StrokeVertexes += len(paths[i].strokes)
+	}
+}
+
+func (p *NullParams) renderTriangles(paint *Paint, scissor *nvgScissor, vertexes []nvgVertex) {
+	if p.CountVertexes {
+		p.TriangleVertexes += len(vertexes)
+	}
+}
+
+func (p *NullParams) renderTriangleStrip(paint *Paint, scissor *nvgScissor, vertexes []nvgVertex) {
+	if p.CountVertexes {
+		p.TriangleVertexes += len(vertexes)
+	}
+}
+
+func (p *NullParams) renderDelete() {
+	p.textures = nil
+}
+
+func (p *NullParams) renderCapabilities() Capabilities {
+	return Capabilities{}
+}
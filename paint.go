@@ -11,9 +11,12 @@ type Paint struct {
 	extent     [2]float32
 	radius     float32
 	feather    float32
+	radiusY    float32
+	featherY   float32
 	innerColor Color
 	outerColor Color
 	image      int
+	repeat     bool
 }
 
 func (p *Paint) setPaintColor(color Color) {
@@ -22,9 +25,53 @@ func (p *Paint) setPaintColor(color Color) {
 	p.extent[1] = 0.0
 	p.radius = 0.0
 	p.feather = 1.0
+	p.radiusY = 0.0
+	p.featherY = 1.0
 	p.innerColor = color
 	p.outerColor = color
 	p.image = 0
+	p.repeat = false
+}
+
+// InnerColor returns the inner (start) color of the paint.
+func (p Paint) InnerColor() Color {
+	return p.innerColor
+}
+
+// OuterColor returns the outer (end) color of the paint.
+func (p Paint) OuterColor() Color {
+	return p.outerColor
+}
+
+// Extent returns the extent of the paint. It is half size of the box for gradients, and size of one image for patterns.
+func (p Paint) Extent() [2]float32 {
+	return p.extent
+}
+
+// Radius returns the corner radius of the paint. It is the horizontal one for BoxGradientAniso().
+func (p Paint) Radius() float32 {
+	return p.radius
+}
+
+// Feather returns the feather (blurriness of the gradient border) of the paint.
+// It is the horizontal one for BoxGradientAniso().
+func (p Paint) Feather() float32 {
+	return p.feather
+}
+
+// ImageHandle returns the handle of the image used by the paint, or 0 for gradients and solid colors.
+func (p Paint) ImageHandle() int {
+	return p.image
+}
+
+// Repeat returns whether the gradient repeats beyond its end points instead of extending the end colors.
+func (p Paint) Repeat() bool {
+	return p.repeat
+}
+
+// Transform returns the transform of the paint space.
+func (p Paint) Transform() TransformMatrix {
+	return p.xform
 }
 
 // LinearGradient creates and returns a linear gradient. Parameters (sx,sy)-(ex,ey) specify the start and end coordinates
@@ -48,11 +95,21 @@ func LinearGradient(sx, sy, ex, ey float32, iColor, oColor Color) Paint {
 		extent:     [2]float32{large, large + d*0.5},
 		radius:     0.0,
 		feather:    maxF(1.0, d),
+		featherY:   maxF(1.0, d),
 		innerColor: iColor,
 		outerColor: oColor,
 	}
 }
 
+// LinearGradientRepeat creates and returns a linear gradient like LinearGradient(), but when repeat is true
+// the gradient is tiled beyond (sx,sy)-(ex,ey) instead of extending the start and end colors: it restarts
+// from icol after each ocol. Animating the transform of a repeated gradient scrolls the stripes.
+func LinearGradientRepeat(sx, sy, ex, ey float32, iColor, oColor Color, repeat bool) Paint {
+	paint := LinearGradient(sx, sy, ex, ey, iColor, oColor)
+	paint.repeat = repeat
+	return paint
+}
+
 // RadialGradient creates and returns a radial gradient. Parameters (cx,cy) specify the center, inr and outr specify
 // the inner and outer radius of the gradient, icol specifies the start color and ocol the end color.
 // The gradient is transformed by the current transform when it is passed to Context.FillPaint() or Context.StrokePaint().
@@ -65,6 +122,7 @@ func RadialGradient(cx, cy, inR, outR float32, iColor, oColor Color) Paint {
 		extent:     [2]float32{r, r},
 		radius:     0.0,
 		feather:    maxF(1.0, f),
+		featherY:   maxF(1.0, f),
 		innerColor: iColor,
 		outerColor: oColor,
 	}
@@ -76,11 +134,20 @@ func RadialGradient(cx, cy, inR, outR float32, iColor, oColor Color) Paint {
 // the border of the rectangle is. Parameter icol specifies the inner color and ocol the outer color of the gradient.
 // The gradient is transformed by the current transform when it is passed to Context.FillPaint() or Context.StrokePaint().
 func BoxGradient(x, y, w, h, r, f float32, iColor, oColor Color) Paint {
+	return BoxGradientAniso(x, y, w, h, r, r, f, f, iColor, oColor)
+}
+
+// BoxGradientAniso creates and returns a box gradient which has different corner radius and feather for
+// horizontal and vertical edges. Parameters (rx,ry) define the corner radius and (fx,fy) the feather in each direction.
+// Other parameters are same as BoxGradient(). It is useful for elongated shadows.
+func BoxGradientAniso(x, y, w, h, rx, ry, fx, fy float32, iColor, oColor Color) Paint {
 	return Paint{
 		xform:      TranslateMatrix(x+w*0.5, y+h*0.5),
 		extent:     [2]float32{w * 0.5, h * 0.5},
-		radius:     r,
-		feather:    maxF(1.0, f),
+		radius:     rx,
+		feather:    maxF(1.0, fx),
+		radiusY:    ry,
+		featherY:   maxF(1.0, fy),
 		innerColor: iColor,
 		outerColor: oColor,
 	}
@@ -90,10 +157,16 @@ func BoxGradient(x, y, w, h, r, f float32, iColor, oColor Color) Paint {
 // (ex,ey) the size of one image, angle rotation around the top-left corner, image is handle to the image to render.
 // The gradient is transformed by the current transform when it is passed to Context.FillPaint() or Context.StrokePaint().
 func ImagePattern(cx, cy, w, h, angle float32, img int, alpha float32) Paint {
+	return ImagePatternColor(cx, cy, w, h, angle, img, RGBAf(1, 1, 1, alpha))
+}
+
+// ImagePatternColor creates and returns an image pattern tinted by the specified color. Parameters are same as ImagePattern().
+// Image colors are multiplied by color. For alpha images made by Context.CreateImageAlpha(), the image is used as
+// coverage of the color.
+func ImagePatternColor(cx, cy, w, h, angle float32, img int, color Color) Paint {
 	xform := RotateMatrix(angle)
 	xform[4] = cx
 	xform[5] = cy
-	color := RGBAf(1, 1, 1, alpha)
 	return Paint{
 		xform:      xform,
 		extent:     [2]float32{w, h},
@@ -102,3 +175,20 @@ func ImagePattern(cx, cy, w, h, angle float32, img int, alpha float32) Paint {
 		outerColor: color,
 	}
 }
+
+// ImagePatternFlipped creates and returns an image pattern mirrored horizontally (flipX) and/or vertically (flipY)
+// within each image. Other parameters are same as ImagePattern().
+func ImagePatternFlipped(cx, cy, w, h, angle float32, img int, alpha float32, flipX, flipY bool) Paint {
+	paint := ImagePattern(cx, cy, w, h, angle, img, alpha)
+	flip := IdentityMatrix()
+	if flipX {
+		flip[0] = -1
+		flip[4] = w
+	}
+	if flipY {
+		flip[3] = -1
+		flip[5] = h
+	}
+	paint.xform = flip.Multiply(paint.xform)
+	return paint
+}
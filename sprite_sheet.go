@@ -0,0 +1,52 @@
+package nanovgo
+
+import (
+	"image"
+)
+
+// SpriteSheet is a set of frames (sub-rectangles) in one image, created by Context.CreateSpriteSheet().
+type SpriteSheet struct {
+	image  int
+	frames []image.Rectangle
+}
+
+// CreateSpriteSheet creates sprite sheet of the image with frame rectangles in image pixels.
+// The image is not owned by the sprite sheet, so it has to be deleted by DeleteImage().
+func (ctx *Context) CreateSpriteSheet(img int, frames []image.Rectangle) *SpriteSheet {
+	return &SpriteSheet{
+		image:  img,
+		frames: append([]image.Rectangle(nil), frames...),
+	}
+}
+
+// Image returns the handle of the image of the sprite sheet.
+func (s *SpriteSheet) Image() int {
+	return s.image
+}
+
+// FrameCount returns the number of the frames.
+func (s *SpriteSheet) FrameCount() int {
+	return len(s.frames)
+}
+
+// Frame returns the rectangle of the frame in image pixels, or an empty rectangle for out of range frame index.
+func (s *SpriteSheet) Frame(frameIndex int) image.Rectangle {
+	if frameIndex < 0 || frameIndex >= len(s.frames) {
+		return image.Rectangle{}
+	}
+	return s.frames[frameIndex]
+}
+
+// Draw fills rectangle (x,y)-(x+w,y+h) with the frame scaled to fit it.
+// The current fill paint and path are not kept. Out of range frame index draws nothing.
+func (s *SpriteSheet) Draw(ctx *Context, frameIndex int, x, y, w, h float32) {
+	if frameIndex < 0 || frameIndex >= len(s.frames) {
+		return
+	}
+	ctx.Block(func() {
+		ctx.BeginPath()
+		ctx.Rect(x, y, w, h)
+		ctx.SetFillPaint(ctx.ImagePatternRegion(x, y, w, h, 0, s.image, s.frames[frameIndex], 1))
+		ctx.Fill()
+	})
+}
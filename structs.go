@@ -1,6 +1,9 @@
 package nanovgo
 
 import (
+	"fmt"
+	"image"
+
 	"nanovgo/fontstashmini"
 )
 
@@ -19,6 +22,51 @@ type nvgParams interface {
 	renderTriangles(paint *Paint, scissor *nvgScissor, vertexes []nvgVertex)
 	renderTriangleStrip(paint *Paint, scissor *nvgScissor, vertexes []nvgVertex)
 	renderDelete()
+	renderCapabilities() Capabilities
+}
+
+// nvgTextRecorder is implemented by backends that keep text as text
+// instead of rendering glyph quads (e.g. SVG output).
+type nvgTextRecorder interface {
+	renderText(paint *Paint, scissor *nvgScissor, xform TransformMatrix, x, y float32, fontName string, fontSize, letterSpacing float32, align Align, runes []rune)
+}
+
+// nvgTextPainter is implemented by backends that can fill glyph quads with gradients and patterns.
+// The quads are a triangle strip and the alpha of the font image is the coverage of the paint.
+type nvgTextPainter interface {
+	renderTextPaint(paint *Paint, scissor *nvgScissor, fontImage int, vertexes []nvgVertex)
+}
+
+// nvgVertexColorRenderer is implemented by backends that interpolate colors between vertexes.
+// There is a color for each vertex of the triangle strip.
+type nvgVertexColorRenderer interface {
+	renderTriangleStripColors(scissor *nvgScissor, vertexes []nvgVertex, colors []Color)
+}
+
+// nvgDocumentWriter is implemented by backends that write a document for each frame when it is flushed,
+// so that EndFrame() flushes them even when nothing was drawn.
+type nvgDocumentWriter interface {
+	writesDocument()
+}
+
+// nvgPaletteRenderer is implemented by backends that look up colors of alpha textures in a palette.
+type nvgPaletteRenderer interface {
+	renderSetTexturePalette(image int, palette []Color) error
+}
+
+// nvgRegionBlurrer is implemented by backends that can blur already rendered pixels.
+type nvgRegionBlurrer interface {
+	renderBlurRegion(scissor *nvgScissor, vertexes []nvgVertex, radius float32)
+}
+
+// nvgStrokeOverlapper is implemented by backends that can draw strokes without overlap.
+type nvgStrokeOverlapper interface {
+	setStrokeOverlapMode(mode OverlapMode)
+}
+
+// nvgPremultipliedOutputter is implemented by backends that can write either straight or premultiplied colors.
+type nvgPremultipliedOutputter interface {
+	setPremultipliedOutput(enabled bool)
 }
 
 type nvgPoint struct {
@@ -29,6 +77,12 @@ type nvgPoint struct {
 	flags    nvgPointFlags
 }
 
+// Vertex is a tessellated vertex returned by Context.FillTriangles(). X, Y are in device space and
+// U, V are the anti-aliasing coordinates passed to the backend.
+type Vertex struct {
+	X, Y, U, V float32
+}
+
 type nvgVertex struct {
 	x, y, u, v float32
 }
@@ -49,11 +103,44 @@ type nvgPath struct {
 	strokes []nvgVertex
 	winding Winding
 	convex  bool
+	// explicitWinding is set when winding was specified by PathWinding()
+	explicitWinding bool
+}
+
+// nvgImageDecoder is a decoder registered by Context.RegisterImageDecoder().
+type nvgImageDecoder struct {
+	format string
+	decode func([]byte) (image.Image, error)
+}
+
+// nvgLayerCall is a draw call recorded by Context.Layer(), with copies of its data.
+type nvgLayerCall struct {
+	z    int
+	draw func(params nvgParams)
+}
+
+// copyPaths copies the paths and their vertexes, which live in the reused vertex buffer of the cache.
+func copyPaths(paths []nvgPath) []nvgPath {
+	result := make([]nvgPath, len(paths))
+	for i := range paths {
+		result[i] = paths[i]
+		result[i].fills = append([]nvgVertex(nil), paths[i].fills...)
+		result[i].strokes = append([]nvgVertex(nil), paths[i].strokes...)
+	}
+	return result
 }
 
 type nvgScissor struct {
-	xform  TransformMatrix
-	extent [2]float32
+	xform   TransformMatrix
+	extent  [2]float32
+	feather float32
+}
+
+// nvgGeneratedImage is the key of images generated by Context for its own drawing.
+type nvgGeneratedImage struct {
+	checker bool
+	dot     bool
+	colors  [2]Color
 }
 
 type nvgState struct {
@@ -62,37 +149,66 @@ type nvgState struct {
 	miterLimit    float32
 	lineJoin      LineCap
 	lineCap       LineCap
+	strokeAlign   StrokeAlign
+	strokeOverlap OverlapMode
+	strokeAlong   bool
+	lineDash      []float32
+	dashOffset    float32
+	dashSpeed     float32
+	strokeColors  [2]Color
 	alpha         float32
+	fillAlpha     float32
+	strokeAlpha   float32
 	xform         TransformMatrix
 	scissor       nvgScissor
 	fontSize      float32
 	letterSpacing float32
+	tabStops      []float32
 	lineHeight    float32
 	fontBlur      float32
 	textAlign     Align
+	decoration    TextDecoration
 	fontID        int
 }
 
 func (s *nvgState) reset() {
+	s.resetStyles()
+	s.xform = IdentityMatrix()
+	s.scissor.xform = IdentityMatrix()
+	s.scissor.xform[0] = 0.0
+	s.scissor.xform[3] = 0.0
+	s.scissor.extent[0] = -1.0
+	s.scissor.extent[1] = -1.0
+	s.scissor.feather = 0.0
+	s.resetText()
+}
+
+func (s *nvgState) resetStyles() {
 	s.fill.setPaintColor(RGBA(255, 255, 255, 255))
 	s.stroke.setPaintColor(RGBA(0, 0, 0, 255))
 	s.strokeWidth = 1.0
 	s.miterLimit = 10.0
 	s.lineCap = Butt
 	s.lineJoin = Miter
+	s.strokeAlign = StrokeCenter
+	s.strokeOverlap = OverlapNaive
+	s.strokeAlong = false
+	s.lineDash = nil
+	s.dashOffset = 0.0
+	s.dashSpeed = 0.0
 	s.alpha = 1.0
-	s.xform = IdentityMatrix()
-	s.scissor.xform = IdentityMatrix()
-	s.scissor.xform[0] = 0.0
-	s.scissor.xform[3] = 0.0
-	s.scissor.extent[0] = -1.0
-	s.scissor.extent[1] = -1.0
+	s.fillAlpha = 1.0
+	s.strokeAlpha = 1.0
+}
 
+func (s *nvgState) resetText() {
 	s.fontSize = 16.0
 	s.letterSpacing = 0.0
+	s.tabStops = nil
 	s.lineHeight = 1.0
 	s.fontBlur = 0.0
 	s.textAlign = AlignLeft | AlignBaseline
+	s.decoration = DecorationNone
 	s.fontID = fontstashmini.INVALID
 }
 
@@ -105,6 +221,7 @@ type nvgPathCache struct {
 	paths    []nvgPath
 	vertexes []nvgVertex
 	bounds   [4]float32
+	maxDepth int
 }
 
 func (c *nvgPathCache) allocVertexes(n int) []nvgVertex {
@@ -138,6 +255,10 @@ func (c *nvgPathCache) lastPoint() *nvgPoint {
 }
 
 func (c *nvgPathCache) addPoint(x, y float32, flags nvgPointFlags, distTol float32) {
+	if !isFiniteF(x) || !isFiniteF(y) {
+		// Keeps NaN out of the bounds and the tessellation, e.g. of commands which were flattened by a transform.
+		return
+	}
 	path := c.lastPath()
 
 	if path.count > 0 && len(c.points) > 0 {
@@ -172,11 +293,14 @@ func (c *nvgPathCache) pathWinding(winding Winding) {
 	path := c.lastPath()
 	if path != nil {
 		path.winding = winding
+		path.explicitWinding = true
 	}
 }
 
 func (c *nvgPathCache) tesselateBezier(x1, y1, x2, y2, x3, y3, x4, y4 float32, level int, flags nvgPointFlags, tessTol, distTol float32) {
-	if level > 10 {
+	if level > c.maxDepth {
+		// Too deep, finish the curve by a straight segment.
+		c.addPoint(x4, y4, flags, distTol)
 		return
 	}
 	dx := x4 - x1
@@ -205,6 +329,31 @@ func (c *nvgPathCache) tesselateBezier(x1, y1, x2, y2, x3, y3, x4, y4 float32, l
 	c.tesselateBezier(x1234, y1234, x234, y234, x34, y34, x4, y4, level+1, flags, tessTol, distTol)
 }
 
+func (c *nvgPathCache) tesselateQuad(x1, y1, x2, y2, x3, y3 float32, level int, flags nvgPointFlags, tessTol, distTol float32) {
+	if level > c.maxDepth {
+		c.addPoint(x3, y3, flags, distTol)
+		return
+	}
+	dx := x3 - x1
+	dy := y3 - y1
+	// Same flatness as the equivalent cubic, whose control points are 2/3 of the way to (x2,y2).
+	d := absF(((x2-x3)*dy - (y2-y3)*dx)) * 4.0 / 3.0
+
+	if d*d < tessTol*(dx*dx+dy*dy) {
+		c.addPoint(x3, y3, flags, distTol)
+		return
+	}
+
+	x12 := (x1 + x2) * 0.5
+	y12 := (y1 + y2) * 0.5
+	x23 := (x2 + x3) * 0.5
+	y23 := (y2 + y3) * 0.5
+	x123 := (x12 + x23) * 0.5
+	y123 := (y12 + y23) * 0.5
+	c.tesselateQuad(x1, y1, x12, y12, x123, y123, level+1, 0, tessTol, distTol)
+	c.tesselateQuad(x123, y123, x23, y23, x3, y3, level+1, flags, tessTol, distTol)
+}
+
 func (c *nvgPathCache) calculateJoins(w float32, lineJoin LineCap, miterLimit float32) {
 	var iw float32
 	if w > 0.0 {
@@ -277,11 +426,172 @@ func (c *nvgPathCache) calculateJoins(w float32, lineJoin LineCap, miterLimit fl
 	}
 }
 
-func (c *nvgPathCache) expandStroke(w float32, lineCap, lineJoin LineCap, miterLimit, fringeWidth, tessTol float32) {
+// offsetPaths moves path points along their extrusion by offset. Positive offset moves points outward of
+// the area enclosed by each sub-path. Open sub-paths are offset as a whole by their end segment normals.
+// It has to be called after calculateJoins(). It returns the original point positions to restore them.
+func (c *nvgPathCache) offsetPaths(offset float32) []float32 {
+	saved := make([]float32, 0, len(c.points)*2)
+	for _, p := range c.points {
+		saved = append(saved, p.x, p.y)
+	}
+	for i := 0; i < len(c.paths); i++ {
+		path := &c.paths[i]
+		points := c.points[path.first : path.first+path.count]
+		if path.count < 2 {
+			continue
+		}
+		d := offset
+		if polyArea(points, path.count) > 0 {
+			d = -offset
+		}
+		for j := range points {
+			p := &points[j]
+			dmx, dmy := p.dmx, p.dmy
+			if !path.closed && j == 0 {
+				dmx, dmy = p.dy, -p.dx
+			} else if !path.closed && j == path.count-1 {
+				dmx, dmy = points[j-1].dy, -points[j-1].dx
+			}
+			p.x += dmx * d
+			p.y += dmy * d
+		}
+	}
+	return saved
+}
+
+// dashPaths replaces the paths by open paths of their dashes. Dashes are measured along the flattened
+// paths, so they keep their lengths around curves. The pattern starts again at each path, and the
+// dashes at the start and the end of a closed path are joined. Paths which would have more than
+// nvgMaxDashSteps dashes and gaps are kept solid.
+func (c *nvgPathCache) dashPaths(dashes []float32, offset, distTol float32) {
+	var total float32
+	for _, dash := range dashes {
+		total += dash
+	}
+	if total <= 0 {
+		return
+	}
+	offset -= floorF(offset/total) * total
+
+	var points []nvgPoint
+	var paths []nvgPath
+	for i := range c.paths {
+		path := &c.paths[i]
+		if path.count < 2 {
+			continue
+		}
+		src := c.points[path.first : path.first+path.count]
+		nSegments := path.count - 1
+		if path.closed {
+			nSegments = path.count
+		}
+
+		// Find the dash at the offset.
+		index := 0
+		remain := dashes[0]
+		d := offset
+		for d >= remain {
+			d -= remain
+			index = (index + 1) % len(dashes)
+			remain = dashes[index]
+		}
+		remain -= d
+		startsOn := index%2 == 0
+
+		var pieces [][]nvgPoint
+		var piece []nvgPoint
+		add := func(x, y float32, flags nvgPointFlags) {
+			if n := len(piece); n > 0 && ptEquals(piece[n-1].x, piece[n-1].y, x, y, distTol) {
+				return
+			}
+			piece = append(piece, nvgPoint{x: x, y: y, flags: flags})
+		}
+		drawing := false
+		// Dashes too short for the precision of the path would never reach the end of a segment.
+		steps := 0
+	segments:
+		for j := 0; j < nSegments; j++ {
+			p0 := &src[j]
+			p1 := &src[(j+1)%path.count]
+			var t float32
+			for {
+				on := index%2 == 0
+				if on && !drawing {
+					piece = nil
+					add(p0.x+p0.dx*t, p0.y+p0.dy*t, 0)
+					drawing = true
+				}
+				if remain <= p0.len-t {
+					t += remain
+					if on {
+						add(p0.x+p0.dx*t, p0.y+p0.dy*t, 0)
+						pieces = append(pieces, piece)
+						drawing = false
+					}
+					index = (index + 1) % len(dashes)
+					remain = dashes[index]
+					if steps++; steps > nvgMaxDashSteps {
+						break segments
+					}
+					continue
+				}
+				remain -= p0.len - t
+				if on {
+					add(p1.x, p1.y, p1.flags)
+				}
+				break
+			}
+		}
+		if steps > nvgMaxDashSteps {
+			paths = append(paths, nvgPath{first: len(points), count: path.count, closed: path.closed, winding: path.winding})
+			points = append(points, src...)
+			continue
+		}
+		if drawing {
+			if path.closed && startsOn && len(pieces) > 0 {
+				pieces[0] = append(piece, pieces[0][1:]...)
+			} else {
+				pieces = append(pieces, piece)
+			}
+		}
+
+		for _, piece := range pieces {
+			if len(piece) < 2 {
+				continue
+			}
+			paths = append(paths, nvgPath{first: len(points), count: len(piece), winding: path.winding})
+			points = append(points, piece...)
+		}
+	}
+
+	// Calculate the direction and length of line segments.
+	for i := range paths {
+		path := &paths[i]
+		for j := 0; j < path.count; j++ {
+			p0 := &points[path.first+j]
+			p1 := &points[path.first+(j+1)%path.count]
+			p0.len, p0.dx, p0.dy = normalize(p1.x-p0.x, p1.y-p0.y)
+		}
+	}
+	c.points = append(c.points[:0], points...)
+	c.paths = append(c.paths[:0], paths...)
+}
+
+func (c *nvgPathCache) restorePoints(saved []float32) {
+	for i := range c.points {
+		c.points[i].x = saved[i*2]
+		c.points[i].y = saved[i*2+1]
+	}
+}
+
+func (c *nvgPathCache) expandStroke(w float32, lineCap, lineJoin LineCap, miterLimit, fringeWidth, tessTol, offset float32) {
 	aa := fringeWidth
-	// Calculate divisions per half circle.
+	// Calculate divisions per half circle, caps have one more vertex than divisions.
 	nCap := curveDivs(w, PI, tessTol)
 	c.calculateJoins(w, lineJoin, miterLimit)
+	if offset != 0 {
+		defer c.restorePoints(c.offsetPaths(offset))
+	}
 
 	// Calculate max vertex usage.
 	countVertex := 0
@@ -295,7 +605,7 @@ func (c *nvgPathCache) expandStroke(w float32, lineCap, lineJoin LineCap, miterL
 		if !path.closed {
 			// space for caps
 			if lineCap == Round {
-				countVertex += (nCap*2 + 2) * 2
+				countVertex += (nCap*2 + 4) * 2
 			} else {
 				countVertex += (3 + 3) * 2
 			}
@@ -309,6 +619,11 @@ func (c *nvgPathCache) expandStroke(w float32, lineCap, lineJoin LineCap, miterL
 		points := c.points[path.first:]
 
 		path.fills = path.fills[:0]
+		if path.count < 2 {
+			// A single point has no direction to stroke along.
+			path.strokes = path.strokes[:0]
+			continue
+		}
 
 		// Calculate fringe or stroke
 		index := 0
@@ -339,7 +654,7 @@ func (c *nvgPathCache) expandStroke(w float32, lineCap, lineJoin LineCap, miterL
 			case Square:
 				index = buttCapStart(dst, index, p0, dx, dy, w, w-aa, aa)
 			case Round:
-				index = roundCapStart(dst, index, p0, dx, dy, w, nCap, aa)
+				index = roundCapStart(dst, index, p0, dx, dy, w, nCap+1, aa)
 			}
 		}
 
@@ -376,7 +691,7 @@ func (c *nvgPathCache) expandStroke(w float32, lineCap, lineJoin LineCap, miterL
 			case Square:
 				index = buttCapEnd(dst, index, p1, dx, dy, w, w-aa, aa)
 			case Round:
-				index = roundCapEnd(dst, index, p1, dx, dy, w, nCap, aa)
+				index = roundCapEnd(dst, index, p1, dx, dy, w, nCap+1, aa)
 			}
 		}
 
@@ -522,3 +837,87 @@ type TextRow struct {
 	Width      float32 // Logical width of the row.
 	MinX, MaxX float32 // Actual bounds of the row. Logical with and bounds can differ because of kerning and some parts over extending.
 }
+
+// PositionedGlyph is a glyph positioned by an external text shaper, used by Context.DrawGlyphRun().
+// GlyphID is the glyph index in the current font. Offsets and advance are in user space units,
+// and YOffset grows downward like other coordinates.
+type PositionedGlyph struct {
+	GlyphID  int
+	XOffset  float32
+	YOffset  float32
+	XAdvance float32
+}
+
+// TextLineLayout keeps the layout of a row drawn by TextBox
+type TextLineLayout struct {
+	Runes      []rune  // The input string, or a copy ending with an ellipsis for a row shortened by TextBoxLimited.
+	StartIndex int     // Index to the input text where the row starts.
+	EndIndex   int     // Index to the input text where the row ends (one past the last character).
+	X, Y       float32 // The location where the row is drawn (with left and current vertical align).
+	Baseline   float32 // The y-coordinate of the baseline of the row.
+	Width      float32 // Width of the row as it is drawn.
+	Offset     float32 // Horizontal offset from the box left edge applied for center/right align.
+}
+
+// Style is a bundle of fill and stroke settings, applied by Context.ApplyStyle() and returned by
+// Context.CurrentStyle(). All fields are applied as they are, so start partial styles from DefaultStyle().
+type Style struct {
+	StrokeWidth float32
+	LineCap     LineCap
+	LineJoin    LineCap
+	MiterLimit  float32
+	StrokeColor Color
+	FillColor   Color
+	GlobalAlpha float32
+}
+
+// Capabilities reports optional features of the rendering backend, returned by Context.Capabilities().
+type Capabilities struct {
+	StencilClip   bool // The backend has a stencil buffer to clip drawing to arbitrary paths.
+	ReadPixels    bool // Rendered pixels can be read back through the context (no backend does it yet).
+	Framebuffers  bool // The context can render to offscreen framebuffers (no backend does it yet).
+	ColorGlyphs   bool // Glyphs can keep their own colors (e.g. emoji) instead of alpha only.
+	VertexColors  bool // Colors are interpolated between vertexes by FillVertexColors().
+	Premultiplied bool // Rendered colors can be premultiplied by alpha, see Context.SetPremultipliedOutput().
+}
+
+// RenderStats counts the draw calls and the triangles submitted to the backend since BeginFrame() or
+// Context.ResetStats(), returned by Context.RenderStats().
+type RenderStats struct {
+	DrawCalls       int
+	FillTriangles   int
+	StrokeTriangles int
+	TextTriangles   int
+}
+
+// ContextOptions configures a context created by NewContextWithOptions().
+// Zero sizes keep the defaults, 512 for the initial font atlas and 2048 for the maximum, limited by the other size.
+// Sizes are powers of two from 64 to 16384 and the initial size can't exceed the maximum.
+type ContextOptions struct {
+	Flags                CreateFlags
+	InitialFontAtlasSize int // Width and height of the first font atlas.
+	MaxFontAtlasSize     int // Width and height the font atlas can grow to when it is full.
+}
+
+// fontAtlasSizes returns the atlas sizes of the options with defaults, or an error if they are invalid.
+func (o *ContextOptions) fontAtlasSizes() (initial, max int, err error) {
+	initial, max = o.InitialFontAtlasSize, o.MaxFontAtlasSize
+	if initial == 0 {
+		initial = nvgInitFontImageSize
+		if max != 0 && max < initial {
+			initial = max
+		}
+	}
+	if max == 0 {
+		max = maxI(nvgMaxFontImageSize, initial)
+	}
+	for _, size := range []int{initial, max} {
+		if size < nvgFontImageSizeMin || size > nvgFontImageSizeMax || size&(size-1) != 0 {
+			return 0, 0, fmt.Errorf("font atlas size %d should be a power of two from %d to %d", size, nvgFontImageSizeMin, nvgFontImageSizeMax)
+		}
+	}
+	if initial > max {
+		return 0, 0, fmt.Errorf("initial font atlas size %d exceeds the maximum %d", initial, max)
+	}
+	return initial, max, nil
+}
@@ -0,0 +1,395 @@
+package nanovgo
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log"
+)
+
+// NewSVGContext makes new NanoVGo context that writes each frame to w as SVG document
+// instead of rendering it.
+//
+// Fills and strokes become <path> elements, gradients become <linearGradient> and
+// <radialGradient> definitions, image patterns become <pattern> definitions and
+// text is kept as <text> elements. Every EndFrame() writes one standalone SVG document.
+// The output reproduces the scene structurally, but it is not pixel-perfect.
+func NewSVGContext(w io.Writer) (*Context, error) {
+	if w == nil {
+		return nil, errors.New("NewSVGContext: writer is nil")
+	}
+	params := &svgParams{
+		writer: w,
+	}
+	return createInternal(params)
+}
+
+type svgTexture struct {
+	id            int
+	width, height int
+	texType       nvgTextureType
+	flags         ImageFlags
+	data          []byte
+}
+
+type svgParams struct {
+	writer    io.Writer
+	view      [2]float32
+	textures  []*svgTexture
+	textureID int
+	defs      bytes.Buffer
+	body      bytes.Buffer
+	defID     int
+}
+
+func (p *svgParams) findTexture(id int) *svgTexture {
+	for _, texture := range p.textures {
+		if texture.id == id {
+			return texture
+		}
+	}
+	return nil
+}
+
+func (p *svgParams) edgeAntiAlias() bool {
+	// SVG renderers anti-alias by themselves, fringes would only add noise.
+	return false
+}
+
+func (p *svgParams) renderCapabilities() Capabilities {
+	// The output is a document, there are no pixels to read back or stencil to clip with.
+	return Capabilities{}
+}
+
+func (p *svgParams) renderCreate() error {
+	return nil
+}
+
+func (p *svgParams) renderCreateTexture(texType nvgTextureType, w, h int, flags ImageFlags, data []byte) int {
+	var tex *svgTexture
+	for _, texture := range p.textures {
+		if texture.id == 0 {
+			tex = texture
+			break
+		}
+	}
+	if tex == nil {
+		tex = &svgTexture{}
+		p.textures = append(p.textures, tex)
+	}
+	p.textureID++
+	tex.id = p.textureID
+	tex.width = w
+	tex.height = h
+	tex.texType = texType
+	tex.flags = flags
+
+	bpp := 1
+	if texType == nvgTextureRGBA {
+		bpp = 4
+	}
+	tex.data = make([]byte, w*h*bpp)
+	copy(tex.data, data)
+	return tex.id
+}
+
+func (p *svgParams) renderDeleteTexture(id int) error {
+	tex := p.findTexture(id)
+	if tex == nil {
+		return errors.New("invalid texture in SVGParams.deleteTexture")
+	}
+	tex.id = 0
+	tex.data = nil
+	return nil
+}
+
+func (p *svgParams) renderUpdateTexture(image, x, y, w, h int, data []byte) error {
+	tex := p.findTexture(image)
+	if tex == nil {
+		return errors.New("invalid texture in SVGParams.updateTexture")
+	}
+	bpp := 1
+	if tex.texType == nvgTextureRGBA {
+		bpp = 4
+	}
+	// Same as GL backend: data covers the whole texture and full rows are updated.
+	start := y * tex.width * bpp
+	end := (y + h) * tex.width * bpp
+	if end > len(tex.data) {
+		end = len(tex.data)
+	}
+	if start < end && end <= len(data) {
+		copy(tex.data[start:end], data[start:end])
+	}
+	return nil
+}
+
+func (p *svgParams) renderGetTextureSize(image int) (int, int, error) {
+	tex := p.findTexture(image)
+	if tex == nil {
+		return -1, -1, errors.New("invalid texture in SVGParams.getTextureSize")
+	}
+	return tex.width, tex.height, nil
+}
+
+func (p *svgParams) renderViewport(width, height int) {
+	p.view[0] = float32(width)
+	p.view[1] = float32(height)
+}
+
+func (p *svgParams) renderCancel() {
+	p.defs.Reset()
+	p.body.Reset()
+	p.defID = 0
+}
+
+func (p *svgParams) writesDocument() {}
+
+func (p *svgParams) renderFlush() {
+	fmt.Fprintf(p.writer, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="%g" height="%g" viewBox="0 0 %g %g">`+"\n",
+		p.view[0], p.view[1], p.view[0], p.view[1])
+	if p.defs.Len() > 0 {
+		io.WriteString(p.writer, "<defs>\n")
+		p.writer.Write(p.defs.Bytes())
+		io.WriteString(p.writer, "</defs>\n")
+	}
+	p.writer.Write(p.body.Bytes())
+	_, err := io.WriteString(p.writer, "</svg>\n")
+	if err != nil {
+		log.Printf("SVGParams.flush: %v\n", err)
+	}
+	p.renderCancel()
+}
+
+func (p *svgParams) renderFill(paint *Paint, scissor *nvgScissor, fringe float32, bounds [4]float32, paths []nvgPath) {
+	var d bytes.Buffer
+	for i := range paths {
+		svgPolygon(&d, paths[i].fills)
+	}
+	if d.Len() == 0 {
+		return
+	}
+	fmt.Fprintf(&p.body, `<path d="%s" fill-rule="nonzero"%s%s/>`+"\n", d.String(), p.paintAttrs("fill", paint), p.clipAttr(scissor))
+}
+
+func (p *svgParams) renderStroke(paint *Paint, scissor *nvgScissor, fringe float32, strokeWidth float32, paths []nvgPath) {
+	var d bytes.Buffer
+	for i := range paths {
+		svgStripOutline(&d, paths[i].strokes, paths[i].closed)
+	}
+	if d.Len() == 0 {
+		return
+	}
+	fmt.Fprintf(&p.body, `<path d="%s" fill-rule="evenodd"%s%s/>`+"\n", d.String(), p.paintAttrs("fill", paint), p.clipAttr(scissor))
+}
+
+func (p *svgParams) renderTriangles(paint *Paint, scissor *nvgScissor, vertexes []nvgVertex) {
+	if p.isFontTexture(paint.image) {
+		// Text is written by renderText as <text> element.
+		return
+	}
+	var d bytes.Buffer
+	for i := 0; i+2 < len(vertexes); i += 3 {
+		svgPolygon(&d, vertexes[i:i+3])
+	}
+	if d.Len() == 0 {
+		return
+	}
+	fmt.Fprintf(&p.body, `<path d="%s"%s%s/>`+"\n", d.String(), p.paintAttrs("fill", paint), p.clipAttr(scissor))
+}
+
+func (p *svgParams) renderTriangleStrip(paint *Paint, scissor *nvgScissor, vertexes []nvgVertex) {
+	if p.isFontTexture(paint.image) {
+		// Text is written by renderText as <text> element.
+		return
+	}
+	var d bytes.Buffer
+	for i := 0; i+2 < len(vertexes); i++ {
+		svgPolygon(&d, vertexes[i:i+3])
+	}
+	if d.Len() == 0 {
+		return
+	}
+	fmt.Fprintf(&p.body, `<path d="%s"%s%s/>`+"\n", d.String(), p.paintAttrs("fill", paint), p.clipAttr(scissor))
+}
+
+func (p *svgParams) renderText(paint *Paint, scissor *nvgScissor, xform TransformMatrix, x, y float32, fontName string, fontSize, letterSpacing float32, align Align, runes []rune) {
+	var anchor, baseline string
+	switch {
+	case align&AlignCenter != 0:
+		anchor = "middle"
+	case align&AlignRight != 0:
+		anchor = "end"
+	default:
+		anchor = "start"
+	}
+	switch {
+	case align&AlignTop != 0:
+		baseline = "text-before-edge"
+	case align&AlignMiddle != 0:
+		baseline = "central"
+	case align&AlignBottom != 0:
+		baseline = "text-after-edge"
+	default:
+		baseline = "alphabetic"
+	}
+	var text bytes.Buffer
+	xml.EscapeText(&text, []byte(string(runes)))
+	var family bytes.Buffer
+	xml.EscapeText(&family, []byte(fontName))
+	fmt.Fprintf(&p.body, `<text x="%g" y="%g" transform="%s" font-family="%s" font-size="%g" letter-spacing="%g" text-anchor="%s" dominant-baseline="%s"%s%s>%s</text>`+"\n",
+		x, y, svgMatrix(xform), family.String(), fontSize, letterSpacing, anchor, baseline, p.paintAttrs("fill", paint), p.clipAttr(scissor), text.String())
+}
+
+func (p *svgParams) renderDelete() {
+	p.textures = nil
+	p.renderCancel()
+}
+
+func (p *svgParams) isFontTexture(image int) bool {
+	tex := p.findTexture(image)
+	return tex != nil && tex.texType == nvgTextureALPHA
+}
+
+func (p *svgParams) nextDefID(prefix string) string {
+	p.defID++
+	return fmt.Sprintf("%s%d", prefix, p.defID)
+}
+
+// paintAttrs converts paint into SVG attributes. Paint doesn't remember which constructor made it,
+// so gradient kind is guessed from its shape in the same way shader interprets it.
+func (p *svgParams) paintAttrs(attr string, paint *Paint) string {
+	if paint.image != 0 {
+		id := p.imagePattern(paint)
+		if id == "" {
+			return fmt.Sprintf(` %s="none"`, attr)
+		}
+		return fmt.Sprintf(` %s="url(#%s)" %s-opacity="%g"`, attr, id, attr, paint.innerColor.A)
+	}
+	if paint.innerColor == paint.outerColor {
+		return fmt.Sprintf(` %s="%s" %s-opacity="%g"`, attr, svgColor(paint.innerColor), attr, paint.innerColor.A)
+	}
+	f := paint.feather
+	var id string
+	switch {
+	case paint.extent[0] >= 1e4:
+		// LinearGradient: gradient runs along y axis of the paint space.
+		id = p.nextDefID("grad")
+		e := paint.extent[1]
+		spread := ""
+		if paint.repeat {
+			spread = ` spreadMethod="repeat"`
+		}
+		fmt.Fprintf(&p.defs, `<linearGradient id="%s" gradientUnits="userSpaceOnUse" x1="0" y1="%g" x2="0" y2="%g"%s gradientTransform="%s">%s</linearGradient>`+"\n",
+			id, e-f*0.5, e+f*0.5, spread, svgMatrix(paint.xform), svgStops(paint, 0, 1))
+	default:
+		// RadialGradient (and BoxGradient approximated as ellipse).
+		id = p.nextDefID("grad")
+		ex := maxF(paint.extent[0], 1e-3)
+		ey := maxF(paint.extent[1], 1e-3)
+		outer := ex + f*0.5
+		inner := maxF(0, ex-f*0.5)
+		xform := ScaleMatrix(1, ey/ex).Multiply(paint.xform)
+		fmt.Fprintf(&p.defs, `<radialGradient id="%s" gradientUnits="userSpaceOnUse" cx="0" cy="0" r="%g" gradientTransform="%s">%s</radialGradient>`+"\n",
+			id, outer, svgMatrix(xform), svgStops(paint, inner/outer, 1))
+	}
+	return fmt.Sprintf(` %s="url(#%s)"`, attr, id)
+}
+
+func (p *svgParams) imagePattern(paint *Paint) string {
+	tex := p.findTexture(paint.image)
+	if tex == nil {
+		return ""
+	}
+	var img image.Image
+	if tex.texType == nvgTextureRGBA {
+		rgba := image.NewNRGBA(image.Rect(0, 0, tex.width, tex.height))
+		copy(rgba.Pix, tex.data)
+		img = rgba
+	} else {
+		gray := image.NewAlpha(image.Rect(0, 0, tex.width, tex.height))
+		copy(gray.Pix, tex.data)
+		img = gray
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return ""
+	}
+	id := p.nextDefID("img")
+	xform := paint.xform
+	if tex.flags&ImageFlippy != 0 {
+		xform = ScaleMatrix(1.0, -1.0).Multiply(xform)
+	}
+	fmt.Fprintf(&p.defs, `<pattern id="%s" patternUnits="userSpaceOnUse" width="%g" height="%g" patternTransform="%s"><image width="%g" height="%g" preserveAspectRatio="none" xlink:href="data:image/png;base64,%s"/></pattern>`+"\n",
+		id, paint.extent[0], paint.extent[1], svgMatrix(xform), paint.extent[0], paint.extent[1], base64.StdEncoding.EncodeToString(buf.Bytes()))
+	return id
+}
+
+func (p *svgParams) clipAttr(scissor *nvgScissor) string {
+	if scissor.extent[0] < -0.5 || scissor.extent[1] < -0.5 {
+		return ""
+	}
+	id := p.nextDefID("clip")
+	ex := scissor.extent[0]
+	ey := scissor.extent[1]
+	fmt.Fprintf(&p.defs, `<clipPath id="%s"><rect x="%g" y="%g" width="%g" height="%g" transform="%s"/></clipPath>`+"\n",
+		id, -ex, -ey, ex*2, ey*2, svgMatrix(scissor.xform))
+	return fmt.Sprintf(` clip-path="url(#%s)"`, id)
+}
+
+func svgColor(c Color) string {
+	return fmt.Sprintf("rgb(%d,%d,%d)", int(clampF(c.R, 0, 1)*255+0.5), int(clampF(c.G, 0, 1)*255+0.5), int(clampF(c.B, 0, 1)*255+0.5))
+}
+
+func svgStops(paint *Paint, start, end float32) string {
+	return fmt.Sprintf(`<stop offset="%g" stop-color="%s" stop-opacity="%g"/><stop offset="%g" stop-color="%s" stop-opacity="%g"/>`,
+		start, svgColor(paint.innerColor), paint.innerColor.A, end, svgColor(paint.outerColor), paint.outerColor.A)
+}
+
+func svgMatrix(t TransformMatrix) string {
+	return fmt.Sprintf("matrix(%g %g %g %g %g %g)", t[0], t[1], t[2], t[3], t[4], t[5])
+}
+
+func svgPolygon(d *bytes.Buffer, vertexes []nvgVertex) {
+	if len(vertexes) < 3 {
+		return
+	}
+	for i := range vertexes {
+		if i == 0 {
+			fmt.Fprintf(d, "M%g %g", vertexes[i].x, vertexes[i].y)
+		} else {
+			fmt.Fprintf(d, "L%g %g", vertexes[i].x, vertexes[i].y)
+		}
+	}
+	d.WriteString("Z")
+}
+
+// svgStripOutline converts stroke triangle strip into outline. Strip has left and right side vertexes
+// interleaved, so outline is left side forward and right side backward.
+func svgStripOutline(d *bytes.Buffer, vertexes []nvgVertex, closed bool) {
+	if len(vertexes) < 4 {
+		return
+	}
+	n := len(vertexes) / 2
+	left := make([]nvgVertex, 0, n)
+	right := make([]nvgVertex, 0, n)
+	for i := 0; i+1 < len(vertexes); i += 2 {
+		left = append(left, vertexes[i])
+		right = append(right, vertexes[i+1])
+	}
+	if closed {
+		// Looping strip forms two rings, even-odd rule leaves the band between them.
+		svgPolygon(d, left)
+		svgPolygon(d, right)
+		return
+	}
+	for i, j := 0, len(right)-1; i < j; i, j = i+1, j-1 {
+		right[i], right[j] = right[j], right[i]
+	}
+	svgPolygon(d, append(left, right...))
+}
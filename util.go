@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"encoding/binary"
 	"math"
+	"runtime"
+	"sort"
+	"strconv"
 )
 
 // DegToRad converts degree to radian.
@@ -16,6 +19,12 @@ func RadToDeg(rad float32) float32 {
 	return rad / PI * 180.0
 }
 
+// NormalizeAngle wraps angle in radian to the range [-PI, PI].
+// The reduction is done in double precision, so it is accurate for large angles.
+func NormalizeAngle(a float32) float32 {
+	return float32(math.Remainder(float64(a), 2*math.Pi))
+}
+
 func signF(a float32) float32 {
 	if a > 0.0 {
 		return 1.0
@@ -104,6 +113,11 @@ func cross(dx0, dy0, dx1, dy1 float32) float32 {
 	return dx1*dy0 - dx0*dy1
 }
 
+// isFiniteF returns false for NaN and infinities.
+func isFiniteF(a float32) bool {
+	return a-a == 0
+}
+
 func absF(a float32) float32 {
 	if a > 0.0 {
 		return a
@@ -118,8 +132,9 @@ func atan2F(a, b float32) float32 {
 	return float32(math.Atan2(float64(a), float64(b)))
 }
 
+// acosF clamps the argument, since dot products of unit vectors can slightly exceed 1 by rounding.
 func acosF(a float32) float32 {
-	return float32(math.Acos(float64(a)))
+	return float32(math.Acos(math.Max(-1, math.Min(1, float64(a)))))
 }
 
 func tanF(a float32) float32 {
@@ -135,6 +150,15 @@ func ceilF(a float32) int {
 	return int(math.Ceil(float64(a)))
 }
 
+func floorF(a float32) float32 {
+	return float32(math.Floor(float64(a)))
+}
+
+// snapHalfPixel snaps a coordinate to the nearest device pixel center.
+func snapHalfPixel(a, ratio float32) float32 {
+	return (floorF(a*ratio) + 0.5) / ratio
+}
+
 func normalize(x, y float32) (float32, float32, float32) {
 	d := float32(math.Sqrt(float64(x*x + y*y)))
 	if d > 1e-6 {
@@ -145,7 +169,9 @@ func normalize(x, y float32) (float32, float32, float32) {
 	return d, x, y
 }
 
-func intersectRects(ax, ay, aw, ah, bx, by, bw, bh float32) [4]float32 {
+// IntersectRects returns the intersection of rectangle a and b as {x, y, w, h}.
+// The size is zero when they don't intersect.
+func IntersectRects(ax, ay, aw, ah, bx, by, bw, bh float32) [4]float32 {
 	minX := maxF(ax, bx)
 	minY := maxF(ay, by)
 	maxX := minF(ax+aw, bx+bw)
@@ -158,6 +184,33 @@ func intersectRects(ax, ay, aw, ah, bx, by, bw, bh float32) [4]float32 {
 	}
 }
 
+// transformRectBounds returns the bounding box {x, y, w, h} of the transformed rectangle.
+func transformRectBounds(xform TransformMatrix, x, y, w, h float32) [4]float32 {
+	x0, y0 := xform.TransformPoint(x, y)
+	x1, y1 := xform.TransformPoint(x+w, y)
+	x2, y2 := xform.TransformPoint(x+w, y+h)
+	x3, y3 := xform.TransformPoint(x, y+h)
+	minX := minFs(x0, x1, x2, x3)
+	minY := minFs(y0, y1, y2, y3)
+	return [4]float32{minX, minY, maxFs(x0, x1, x2, x3) - minX, maxFs(y0, y1, y2, y3) - minY}
+}
+
+// UnionRects returns the smallest rectangle which contains both rectangle a and b as {x, y, w, h}.
+// The Context.UnionRects() method makes the outline of the union of rectangles instead.
+func UnionRects(ax, ay, aw, ah, bx, by, bw, bh float32) [4]float32 {
+	minX := minF(ax, bx)
+	minY := minF(ay, by)
+	maxX := maxF(ax+aw, bx+bw)
+	maxY := maxF(ay+ah, by+bh)
+	return [4]float32{minX, minY, maxX - minX, maxY - minY}
+}
+
+// RectContains returns whether point (px,py) is inside the rectangle.
+// Left and top edges are inside, right and bottom edges are outside like pixel coverage.
+func RectContains(rx, ry, rw, rh, px, py float32) bool {
+	return px >= rx && py >= ry && px < rx+rw && py < ry+rh
+}
+
 func ptEquals(x1, y1, x2, y2, tol float32) bool {
 	dx := x2 - x1
 	dy := y2 - y1
@@ -170,10 +223,11 @@ func distPtSeg(x, y, px, py, qx, qy float32) float32 {
 	dx := x - px
 	dy := y - py
 	d := pqx*pqx + pqy*pqy
-	t := clampF(pqx*dx+pqy*dy, 0.0, 1.1)
+	t := pqx*dx + pqy*dy
 	if d > 0 {
 		t /= d
 	}
+	t = clampF(t, 0.0, 1.0)
 	dx = px + t*pqx - x
 	dy = py + t*pqy - y
 	return dx*dx + dy*dy
@@ -198,6 +252,18 @@ func polyArea(points []nvgPoint, npts int) float32 {
 	return area * 0.5
 }
 
+// polyContains tests whether the point is inside the polygon with even-odd rule.
+func polyContains(points []nvgPoint, npts int, x, y float32) bool {
+	inside := false
+	for i, j := 0, npts-1; i < npts; j, i = i, i+1 {
+		a, b := &points[i], &points[j]
+		if (a.y > y) != (b.y > y) && x < a.x+(y-a.y)*(b.x-a.x)/(b.y-a.y) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
 func polyReverse(points []nvgPoint, npts int) {
 	i := 0
 	j := npts - 1
@@ -208,11 +274,50 @@ func polyReverse(points []nvgPoint, npts int) {
 	}
 }
 
+// simplifyPolyline returns whether each point is kept by Ramer-Douglas-Peucker simplification of the polyline,
+// which removes points closer than tol to the segment between the kept points around them. The end points are kept.
+func simplifyPolyline(points []nvgPoint, tol float32) []bool {
+	keep := make([]bool, len(points))
+	if len(points) == 0 {
+		return keep
+	}
+	keep[0], keep[len(points)-1] = true, true
+	stack := [][2]int{{0, len(points) - 1}}
+	for len(stack) > 0 {
+		first, last := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+		farthest, maxDist := -1, tol*tol
+		for i := first + 1; i < last; i++ {
+			p, a, b := &points[i], &points[first], &points[last]
+			if d := distPtSeg(p.x, p.y, a.x, a.y, b.x, b.y); d > maxDist {
+				farthest, maxDist = i, d
+			}
+		}
+		if farthest >= 0 {
+			keep[farthest] = true
+			stack = append(stack, [2]int{first, farthest}, [2]int{farthest, last})
+		}
+	}
+	return keep
+}
+
 func curveDivs(r, arc, tol float32) int {
 	da := math.Acos(float64(r/(r+tol))) * 2.0
 	return maxI(2, int(math.Ceil(float64(arc)/da)))
 }
 
+// arcDivs returns the number of cubic bezier segments used for an arc of radius r and angle da,
+// so that each segment spans at most 90 degrees and its radial error stays below tol.
+func arcDivs(r, da, tol float32) int {
+	n := int(absF(da)/(PI*0.5) + 0.5)
+	if r > 0 && tol > 0 {
+		// Radial error of cubic approximation is about r*4/27*(a/4)^6 for segment angle a.
+		maxAngle := 4.0 * float32(math.Pow(float64(27.0*tol/(4.0*r)), 1.0/6.0))
+		n = maxI(n, ceilF(absF(da)/maxAngle))
+	}
+	return clampI(n, 1, nvgMaxArcDivs)
+}
+
 func chooseBevel(bevel bool, p0, p1 *nvgPoint, w float32) (x0, y0, x1, y1 float32) {
 	if bevel {
 		x0 = p1.x + p0.dy*w
@@ -383,7 +488,7 @@ func buttCapEnd(dst []nvgVertex, index int, p *nvgPoint, dx, dy, w, d, aa float3
 	(&dst[index]).set(px+dlx*w, py+dly*w, 0, 1)
 	(&dst[index+1]).set(px-dlx*w, py-dly*w, 1, 1)
 	(&dst[index+2]).set(px+dlx*w+dx*aa, py+dly*w+dy*aa, 0, 0)
-	(&dst[index+3]).set(px-dlx*w+dx*aa, py-dly*w-dy*aa, 1, 0)
+	(&dst[index+3]).set(px-dlx*w+dx*aa, py-dly*w+dy*aa, 1, 0)
 	return index + 4
 }
 
@@ -452,3 +557,204 @@ func readUint32(data []byte) (ret *uint32) {
 	binary.Read(buf, binary.LittleEndian, ret)
 	return
 }
+
+// goroutineID returns the ID of the current goroutine, parsed from its stack header ("goroutine N [...").
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
+
+// minEnclosingCircle returns the smallest circle which encloses the points (Welzl's algorithm).
+// The points are shuffled to run in expected linear time.
+func minEnclosingCircle(points [][2]float32) (cx, cy, r float32) {
+	// Deterministic shuffle by linear congruential generator.
+	seed := uint32(len(points))
+	for i := len(points) - 1; i > 0; i-- {
+		seed = seed*1664525 + 1013904223
+		j := int(seed % uint32(i+1))
+		points[i], points[j] = points[j], points[i]
+	}
+	const eps = 1e-4
+	inside := func(p [2]float32) bool {
+		dx := p[0] - cx
+		dy := p[1] - cy
+		return dx*dx+dy*dy <= r*r*(1+eps)+eps
+	}
+	cx, cy = points[0][0], points[0][1]
+	for i := 1; i < len(points); i++ {
+		if inside(points[i]) {
+			continue
+		}
+		cx, cy, r = points[i][0], points[i][1], 0
+		for j := 0; j < i; j++ {
+			if inside(points[j]) {
+				continue
+			}
+			cx = (points[i][0] + points[j][0]) * 0.5
+			cy = (points[i][1] + points[j][1]) * 0.5
+			dx := points[i][0] - cx
+			dy := points[i][1] - cy
+			r = sqrtF(dx*dx + dy*dy)
+			for k := 0; k < j; k++ {
+				if inside(points[k]) {
+					continue
+				}
+				cx, cy, r = circumCircle(points[i], points[j], points[k])
+			}
+		}
+	}
+	return cx, cy, r
+}
+
+// circumCircle returns the circle through three points. For collinear points, it returns
+// the circle whose diameter is the farthest pair.
+func circumCircle(a, b, c [2]float32) (cx, cy, r float32) {
+	bx, by := b[0]-a[0], b[1]-a[1]
+	qx, qy := c[0]-a[0], c[1]-a[1]
+	d := 2 * (bx*qy - by*qx)
+	if absF(d) < 1e-9 {
+		pairs := [3][2][2]float32{{a, b}, {a, c}, {b, c}}
+		for _, pair := range pairs {
+			px := (pair[0][0] + pair[1][0]) * 0.5
+			py := (pair[0][1] + pair[1][1]) * 0.5
+			pr := sqrtF((pair[0][0]-px)*(pair[0][0]-px) + (pair[0][1]-py)*(pair[0][1]-py))
+			if pr > r {
+				cx, cy, r = px, py, pr
+			}
+		}
+		return
+	}
+	b2 := bx*bx + by*by
+	q2 := qx*qx + qy*qy
+	ux := (qy*b2 - by*q2) / d
+	uy := (bx*q2 - qx*b2) / d
+	return a[0] + ux, a[1] + uy, sqrtF(ux*ux + uy*uy)
+}
+
+// rectUnionOutlines returns the outlines of the union of the rectangles {x, y, w, h} as x, y pairs. The outlines
+// go clockwise on screen around insides and counterclockwise around holes, and have only their corners.
+func rectUnionOutlines(rects [][4]float32) [][]float32 {
+	var xs, ys []float32
+	for _, r := range rects {
+		if r[2] > 0 && r[3] > 0 {
+			xs = append(xs, r[0], r[0]+r[2])
+			ys = append(ys, r[1], r[1]+r[3])
+		}
+	}
+	xs, ys = sortedUniqueF(xs), sortedUniqueF(ys)
+	nx, ny := len(xs)-1, len(ys)-1
+	if nx < 1 || ny < 1 {
+		return nil
+	}
+
+	// Cells of the grid made by all edges of the rectangles.
+	covered := make([]bool, nx*ny)
+	search := func(v []float32, a float32) int {
+		return sort.Search(len(v), func(i int) bool { return v[i] >= a })
+	}
+	for _, r := range rects {
+		if !(r[2] > 0 && r[3] > 0) {
+			continue
+		}
+		i0, i1 := search(xs, r[0]), search(xs, r[0]+r[2])
+		j0, j1 := search(ys, r[1]), search(ys, r[1]+r[3])
+		for j := j0; j < j1; j++ {
+			for i := i0; i < i1; i++ {
+				covered[j*nx+i] = true
+			}
+		}
+	}
+	inside := func(i, j int) bool {
+		return i >= 0 && j >= 0 && i < nx && j < ny && covered[j*nx+i]
+	}
+
+	// Boundary edges between covered and empty cells with the cell on their right, by start vertex.
+	vertex := func(i, j int) int { return j*(nx+1) + i }
+	edges := make([][]int, (nx+1)*(ny+1))
+	for j := 0; j < ny; j++ {
+		for i := 0; i < nx; i++ {
+			if !inside(i, j) {
+				continue
+			}
+			if !inside(i, j-1) {
+				edges[vertex(i, j)] = append(edges[vertex(i, j)], vertex(i+1, j))
+			}
+			if !inside(i+1, j) {
+				edges[vertex(i+1, j)] = append(edges[vertex(i+1, j)], vertex(i+1, j+1))
+			}
+			if !inside(i, j+1) {
+				edges[vertex(i+1, j+1)] = append(edges[vertex(i+1, j+1)], vertex(i, j+1))
+			}
+			if !inside(i-1, j) {
+				edges[vertex(i, j+1)] = append(edges[vertex(i, j+1)], vertex(i, j))
+			}
+		}
+	}
+	direction := func(a, b int) (int, int) {
+		return b%(nx+1) - a%(nx+1), b/(nx+1) - a/(nx+1)
+	}
+
+	var loops [][]float32
+	for start := range edges {
+		for len(edges[start]) > 0 {
+			var corners []int
+			from, to := start, edges[start][0]
+			edges[start] = edges[start][1:]
+			firstX, firstY := direction(from, to)
+			for to != start {
+				dx, dy := direction(from, to)
+				next := -1
+				// Where the insides of two cells touch only at the corner, turn right first to keep them apart.
+				for k, candidate := range edges[to] {
+					cx, cy := direction(to, candidate)
+					if cx == -dy && cy == dx {
+						next = k
+						break
+					} else if next == -1 || cx == dx && cy == dy {
+						next = k
+					}
+				}
+				if next == -1 {
+					break
+				}
+				end := edges[to][next]
+				if cx, cy := direction(to, end); cx != dx || cy != dy {
+					corners = append(corners, to)
+				}
+				edges[to] = append(edges[to][:next], edges[to][next+1:]...)
+				from, to = to, end
+			}
+			if dx, dy := direction(from, to); dx != firstX || dy != firstY {
+				corners = append(corners, start)
+			}
+			if to != start || len(corners) < 3 {
+				continue
+			}
+			loop := make([]float32, 0, len(corners)*2)
+			for _, c := range corners {
+				loop = append(loop, xs[c%(nx+1)], ys[c/(nx+1)])
+			}
+			loops = append(loops, loop)
+		}
+	}
+	return loops
+}
+
+// sortedUniqueF sorts the values and removes the duplicates in place.
+func sortedUniqueF(v []float32) []float32 {
+	sort.Slice(v, func(i, j int) bool { return v[i] < v[j] })
+	n := 0
+	for i, a := range v {
+		if i == 0 || a != v[n-1] {
+			v[n] = a
+			n++
+		}
+	}
+	return v[:n]
+}